@@ -52,7 +52,11 @@ func (b *Builder) BeginMap(_ int64) (datamodel.MapAssembler, error) {
 	if err != nil {
 		return nil, err
 	}
-	b.muts = tree.NewMutations()
+	cp, err := b.cfg.CompareFunc()
+	if err != nil {
+		return nil, err
+	}
+	b.muts = tree.NewMutationsWithCompareFunc(cp)
 	return &TreeAssembler{muts: b.muts}, nil
 }
 
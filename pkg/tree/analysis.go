@@ -0,0 +1,304 @@
+package tree
+
+import (
+	"context"
+	"fmt"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipld/go-ipld-prime"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"math/rand"
+	"sort"
+)
+
+// DefaultAnalysisEdits is the number of random single-key edits measured for every config if AnalysisOptions.Edits is 0
+const DefaultAnalysisEdits = 100
+
+// AnalysisOptions controls how AnalyzeConfigs measures the configs
+type AnalysisOptions struct {
+	// Edits is the number of random single-key edits to measure write amplification, DefaultAnalysisEdits if 0. It must
+	// not be negative.
+	Edits int
+	// Seed makes the chosen keys and new values reproducible
+	Seed int64
+}
+
+// SizeDistribution summarizes the encoded sizes of nodes in bytes
+type SizeDistribution struct {
+	Count int
+	Min   int
+	Max   int
+	Mean  float64
+	P50   int
+	P90   int
+	P99   int
+}
+
+func newSizeDistribution(sizes []int) SizeDistribution {
+	if len(sizes) == 0 {
+		return SizeDistribution{}
+	}
+	sort.Ints(sizes)
+	total := 0
+	for _, size := range sizes {
+		total += size
+	}
+	percentile := func(p int) int {
+		return sizes[(len(sizes)-1)*p/100]
+	}
+	return SizeDistribution{
+		Count: len(sizes),
+		Min:   sizes[0],
+		Max:   sizes[len(sizes)-1],
+		Mean:  float64(total) / float64(len(sizes)),
+		P50:   percentile(50),
+		P90:   percentile(90),
+		P99:   percentile(99),
+	}
+}
+
+// ConfigReport is the shape of the tree built from the sample data with the config and the cost of editing it
+type ConfigReport struct {
+	Config *TreeConfig
+	// Depth is the number of nodes read by a lookup
+	Depth int
+	// BlockCount is the number of nodes in the tree
+	BlockCount  int
+	LeafSizes   SizeDistribution
+	BranchSizes SizeDistribution
+	// NodesWritten is the average number of new nodes written by a single-key edit
+	NodesWritten float64
+	// WriteAmplification is the average of bytes of new nodes written by a single-key edit divided by the bytes of the
+	// edited pair(key and encoded value)
+	WriteAmplification float64
+}
+
+// AnalyzeConfigs builds trees from the sample pairs with every config in an in-memory NodeStore and reports their shape
+// and the write amplification of random single-key edits, so chunking parameters can be chosen from the data. The
+// pairs need not be sorted but keys must be unique. The reports are in the order of the configs.
+func AnalyzeConfigs(ctx context.Context, keys [][]byte, vals []ipld.Node, cfgs []*TreeConfig, opts *AnalysisOptions) ([]*ConfigReport, error) {
+	if len(keys) != len(vals) {
+		return nil, fmt.Errorf("the number of keys(%d) and values(%d) are different", len(keys), len(vals))
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("empty sample")
+	}
+	if opts == nil {
+		opts = &AnalysisOptions{}
+	}
+	if opts.Edits < 0 {
+		return nil, fmt.Errorf("negative number of edits: %d", opts.Edits)
+	}
+
+	for i, cfg := range cfgs {
+		if cfg == nil {
+			return nil, fmt.Errorf("nil config at index %d", i)
+		}
+	}
+
+	reports := make([]*ConfigReport, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		report, err := analyzeConfig(ctx, keys, vals, cfg, opts)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// RecommendConfig returns the report of the config with the smallest depth, which decides the block reads per lookup,
+// ties are broken by the smaller write amplification. It favors reads: a smaller depth usually comes from larger nodes,
+// and every edit rewrites whole nodes, so the recommended config may have a much higher write amplification than the
+// others. Use RecommendConfigBy for write-heavy workloads or other objectives.
+func RecommendConfig(reports []*ConfigReport) *ConfigReport {
+	return RecommendConfigBy(reports, func(a, b *ConfigReport) bool {
+		return a.Depth < b.Depth || a.Depth == b.Depth && a.WriteAmplification < b.WriteAmplification
+	})
+}
+
+// RecommendConfigBy returns the best report by the objective, better reports whether a is better than b. The first
+// one is returned among equally good reports and nil if there is no report.
+func RecommendConfigBy(reports []*ConfigReport, better func(a, b *ConfigReport) bool) *ConfigReport {
+	var best *ConfigReport
+	for _, report := range reports {
+		if best == nil || better(report, best) {
+			best = report
+		}
+	}
+	return best
+}
+
+// analysisStore is the in-memory NodeStore whose block sizes can be read
+type analysisStore struct {
+	*BlockNodeStore
+	bs blockstore.Blockstore
+}
+
+func newAnalysisStore() (*analysisStore, error) {
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	ns, err := NewBlockNodeStore(bs, &StoreConfig{CacheSize: 1 << 14})
+	if err != nil {
+		return nil, err
+	}
+	return &analysisStore{BlockNodeStore: ns, bs: bs}, nil
+}
+
+// walkNewNodes calls fn with every node of the tree not in known and its encoded size, known is updated with them.
+// Subtrees of known nodes are skipped.
+func (as *analysisStore) walkNewNodes(ctx context.Context, root cid.Cid, known map[cid.Cid]bool,
+	fn func(n *ProllyNode, size int)) error {
+	if known[root] {
+		return nil
+	}
+	known[root] = true
+	n, err := as.ReadNode(ctx, root)
+	if err != nil {
+		return err
+	}
+	size, err := as.bs.GetSize(ctx, root)
+	if err != nil {
+		return err
+	}
+	fn(n, size)
+	if n.IsLeaf {
+		return nil
+	}
+	for i := 0; i < n.ItemCount(); i++ {
+		err = as.walkNewNodes(ctx, n.GetIdxLink(i), known, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func analyzeConfig(ctx context.Context, keys [][]byte, vals []ipld.Node, cfg *TreeConfig, opts *AnalysisOptions) (*ConfigReport, error) {
+	cp, err := cfg.CompareFunc()
+	if err != nil {
+		return nil, err
+	}
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return cp(keys[order[i]], keys[order[j]]) < 0
+	})
+	sortedKeys := make([][]byte, len(keys))
+	sortedVals := make([]ipld.Node, len(vals))
+	for i, idx := range order {
+		sortedKeys[i] = keys[idx]
+		sortedVals[i] = vals[idx]
+		if i > 0 && cp(sortedKeys[i-1], sortedKeys[i]) == 0 {
+			return nil, fmt.Errorf("duplicated key in sample: %x", sortedKeys[i])
+		}
+	}
+
+	ns, err := newAnalysisStore()
+	if err != nil {
+		return nil, err
+	}
+	framework, err := NewFramework(ctx, ns, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = framework.AppendBatch(ctx, sortedKeys, sortedVals)
+	if err != nil {
+		return nil, err
+	}
+	_, treeCid, err := framework.BuildTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := LoadProllyTreeFromRootCid(treeCid, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ConfigReport{Config: cfg}
+	report.Depth, err = tree.height()
+	if err != nil {
+		return nil, err
+	}
+	var leafSizes, branchSizes []int
+	known := make(map[cid.Cid]bool)
+	err = ns.walkNewNodes(ctx, tree.Root, known, func(n *ProllyNode, size int) {
+		if n.IsLeaf {
+			leafSizes = append(leafSizes, size)
+		} else {
+			branchSizes = append(branchSizes, size)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.BlockCount = len(leafSizes) + len(branchSizes)
+	report.LeafSizes = newSizeDistribution(leafSizes)
+	report.BranchSizes = newSizeDistribution(branchSizes)
+
+	edits := opts.Edits
+	if edits == 0 {
+		edits = DefaultAnalysisEdits
+	}
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	nodeCoder := NewNodeCoder()
+	err = nodeCoder.InitEncoder(cfg.Codec)
+	if err != nil {
+		return nil, err
+	}
+	var nodesWritten, amplification float64
+	for i := 0; i < edits; i++ {
+		idx := rnd.Intn(len(sortedKeys))
+		oldVal, err := nodeCoder.EncodeNode(sortedVals[idx])
+		if err != nil {
+			return nil, err
+		}
+		// a random value of about the same size, so the edit barely changes the size of the node
+		newBytes := make([]byte, len(oldVal))
+		rnd.Read(newBytes)
+		newVal := basicnode.NewBytes(newBytes)
+		newValBytes, err := nodeCoder.EncodeNode(newVal)
+		if err != nil {
+			return nil, err
+		}
+
+		// every edit starts from the original tree
+		edited, err := LoadProllyTreeFromRootCid(treeCid, ns)
+		if err != nil {
+			return nil, err
+		}
+		err = edited.Mutate()
+		if err != nil {
+			return nil, err
+		}
+		err = edited.Put(ctx, sortedKeys[idx], newVal)
+		if err != nil {
+			return nil, err
+		}
+		_, err = edited.Rebuild(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// only nodes of the original tree are known, new nodes of other edits are counted again
+		editKnown := make(map[cid.Cid]bool, len(known))
+		for c := range known {
+			editKnown[c] = true
+		}
+		written, writtenBytes := 0, 0
+		err = ns.walkNewNodes(ctx, edited.Root, editKnown, func(n *ProllyNode, size int) {
+			written++
+			writtenBytes += size
+		})
+		if err != nil {
+			return nil, err
+		}
+		nodesWritten += float64(written)
+		amplification += float64(writtenBytes) / float64(len(sortedKeys[idx])+len(newValBytes))
+	}
+	report.NodesWritten = nodesWritten / float64(edits)
+	report.WriteAmplification = amplification / float64(edits)
+	return report, nil
+}
@@ -0,0 +1,65 @@
+package tree
+
+import (
+	"context"
+	"github.com/zeebo/assert"
+	"testing"
+)
+
+func TestAnalyzeConfigs(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(10000)
+	// unsorted sample
+	testKeys[0], testKeys[9999] = testKeys[9999], testKeys[0]
+
+	cfg := DefaultChunkConfig()
+	wideCfg := DefaultChunkConfig()
+	wideCfg.Branch = &BranchConfig{
+		MinNodeSize:    1 << 15,
+		MaxNodeSize:    1 << 16,
+		MaxPairsInNode: 4000,
+	}
+	keyOnly := true
+	keyOnlyCfg := DefaultChunkConfig()
+	keyOnlyCfg.KeyOnlyChunking = &keyOnly
+	keyOnlyCfg.MaxNodeSize = 1 << 14
+	keyOnlyCfg.Strategy.Suffix.ChunkingFactor = 5
+
+	reports, err := AnalyzeConfigs(ctx, testKeys, testVals, []*TreeConfig{cfg, wideCfg, keyOnlyCfg},
+		&AnalysisOptions{Edits: 20, Seed: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, len(reports), 3)
+	for _, report := range reports {
+		assert.Equal(t, report.BlockCount, report.LeafSizes.Count+report.BranchSizes.Count)
+		assert.True(t, report.LeafSizes.Min <= report.LeafSizes.P50)
+		assert.True(t, report.LeafSizes.P50 <= report.LeafSizes.P99)
+		assert.True(t, report.LeafSizes.P99 <= report.LeafSizes.Max)
+		// the whole path to the key is rewritten at least
+		assert.True(t, report.NodesWritten >= float64(report.Depth))
+		assert.True(t, report.WriteAmplification > 1)
+	}
+	assert.True(t, reports[0].LeafSizes.Max <= cfg.MaxNodeSize)
+	assert.True(t, reports[1].Depth < reports[0].Depth)
+	// the same leaf nodes with fewer branch nodes
+	assert.Equal(t, reports[1].LeafSizes, reports[0].LeafSizes)
+	assert.True(t, reports[1].BranchSizes.Count < reports[0].BranchSizes.Count)
+	// a value edit only rewrites the path in key-only chunking
+	assert.Equal(t, reports[2].NodesWritten, float64(reports[2].Depth))
+	assert.Equal(t, RecommendConfig(reports), reports[1])
+	// or by the write amplification for write-heavy workloads
+	best := RecommendConfigBy(reports, func(a, b *ConfigReport) bool {
+		return a.WriteAmplification < b.WriteAmplification
+	})
+	for _, report := range reports {
+		assert.True(t, best.WriteAmplification <= report.WriteAmplification)
+	}
+	assert.Nil(t, RecommendConfig(nil))
+
+	_, err = AnalyzeConfigs(ctx, testKeys, testVals, []*TreeConfig{cfg}, &AnalysisOptions{Edits: -1})
+	assert.Error(t, err)
+	_, err = AnalyzeConfigs(ctx, testKeys, testVals, []*TreeConfig{cfg, nil}, nil)
+	assert.Error(t, err)
+	testKeys[1] = testKeys[2]
+	_, err = AnalyzeConfigs(ctx, testKeys, testVals, []*TreeConfig{cfg}, nil)
+	assert.Error(t, err)
+}
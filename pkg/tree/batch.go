@@ -1,15 +1,17 @@
 package tree
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/ipld/go-ipld-prime"
 	"io"
 	"sort"
-	"time"
+	"sync"
 )
 
-const (
-	TimeOutLimit = time.Second * 100
+var (
+	DiffsClosed = errors.New("diffs closed")
 )
 
 type op int
@@ -35,10 +37,15 @@ type Mutations struct {
 }
 
 func NewMutations() *Mutations {
+	return NewMutationsWithCompareFunc(DefaultCompareFunc)
+}
+
+// NewMutationsWithCompareFunc returns the Mutations sorted by the key order of the tree
+func NewMutationsWithCompareFunc(cp CompareFunc) *Mutations {
 	return &Mutations{
 		muts:        make([]*Mutation, 0),
 		kmap:        make(map[string]int),
-		compareFunc: DefaultCompareFunc,
+		compareFunc: cp,
 	}
 }
 
@@ -83,7 +90,7 @@ func (m *Mutations) Finish() {
 	m.finish = true
 
 	sort.Slice(m.muts, func(i, j int) bool {
-		if DefaultCompareFunc(m.muts[i].Key, m.muts[j].Key) < 0 {
+		if m.compareFunc(m.muts[i].Key, m.muts[j].Key) < 0 {
 			return true
 		}
 		return false
@@ -126,36 +133,103 @@ func (m *Mutations) Get(item []byte) (ipld.Node, error) {
 	return nil, nil
 }
 
+// DiffEntry is a key changed between two trees. Old is nil if the key is added and New is nil if the key is removed.
+type DiffEntry struct {
+	Key []byte
+	Op  op
+	Old ipld.Node
+	New ipld.Node
+}
+
+// Mutation returns the mutation which applies the change to the old tree
+func (de *DiffEntry) Mutation() *Mutation {
+	return &Mutation{
+		Key: de.Key,
+		Val: de.New,
+		Op:  de.Op,
+	}
+}
+
+// Diffs receives the changes from a producer goroutine. The producer stops if the context of Diff is canceled or Close
+// is called, its error(including ctx.Err()) is returned by NextEntry after all changes before the error.
 type Diffs struct {
-	ch chan *Mutation
+	ch        chan *DiffEntry
+	closing   chan struct{}
+	closeOnce sync.Once
+	// written by the producer before closing ch
+	err error
+
+	// the following fields are only used by the consumer
+	finished     bool
+	errDelivered bool
+	closed       bool
 }
 
 func NewDiffs() *Diffs {
-	return &Diffs{make(chan *Mutation, 10)}
+	return &Diffs{
+		ch:      make(chan *DiffEntry, 10),
+		closing: make(chan struct{}),
+	}
 }
 
-func (d *Diffs) Close() error {
+// AddEntry sends the change to the consumer, it returns error if the Diffs is closed or the ctx is canceled
+func (d *Diffs) AddEntry(ctx context.Context, entry *DiffEntry) error {
+	select {
+	case d.ch <- entry:
+		return nil
+	case <-d.closing:
+		return DiffsClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// finish is called by the producer when it stops, err is nil if all changes have been sent
+func (d *Diffs) finish(err error) {
+	d.err = err
 	close(d.ch)
-	return nil
 }
 
-func (d *Diffs) AddMutation(mut *Mutation) error {
-	select {
-	case <-time.After(TimeOutLimit):
-		return fmt.Errorf("timeout")
-	case d.ch <- mut:
-		return nil
+// NextEntry returns the next changed key, io.EOF if all changes have been returned or the error which stopped the
+// producer
+func (d *Diffs) NextEntry() (*DiffEntry, error) {
+	if !d.finished && !d.closed {
+		entry, ok := <-d.ch
+		if ok {
+			return entry, nil
+		}
+		d.finished = true
 	}
+	if !d.closed && d.err != nil && !d.errDelivered {
+		d.errDelivered = true
+		return nil, d.err
+	}
+	return nil, io.EOF
 }
 
+// NextMutations returns the mutation of the next changed key, io.EOF if all changes have been returned
 func (d *Diffs) NextMutations() (*Mutation, error) {
-	select {
-	case mut, ok := <-d.ch:
-		if !ok {
-			return nil, io.EOF
-		}
-		return mut, nil
-	case <-time.After(TimeOutLimit):
-		return nil, fmt.Errorf("timeout")
+	entry, err := d.NextEntry()
+	if err != nil {
+		return nil, err
+	}
+	return entry.Mutation(), nil
+}
+
+// Err returns the error which stopped the producer, it is nil before the producer finished or if all changes have been
+// sent
+func (d *Diffs) Err() error {
+	if !d.finished || d.err == DiffsClosed {
+		return nil
 	}
+	return d.err
+}
+
+// Close stops the producer goroutine, the remaining changes are dropped
+func (d *Diffs) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.closing)
+	})
+	d.closed = true
+	return nil
 }
@@ -88,7 +88,7 @@ func (cfg *TreeConfig) ToNode() (n ipld.Node, err error) {
 			err = toError(r)
 		}
 	}()
-	n = bindnode.Wrap(cfg, ChunkConfigPrototype.Type()).Representation()
+	n = bindnode.Wrap(cfg.fillOptionalFields(), ChunkConfigPrototype.Type()).Representation()
 	return
 }
 
@@ -162,3 +162,57 @@ func UnwrapProof(node ipld.Node) (*Proof, error) {
 	}
 	return nd, nil
 }
+
+func (pb *ProofBundle) ToNode() (nd ipld.Node, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = toError(r)
+		}
+	}()
+	nd = bindnode.Wrap(pb, ProofBundlePrototype.Type()).Representation()
+	return
+}
+
+func UnwrapProofBundle(node ipld.Node) (*ProofBundle, error) {
+	if node.Prototype() != ProofBundlePrototype {
+		pbBuilder := ProofBundlePrototype.NewBuilder()
+		err := pbBuilder.AssignNode(node)
+		if err != nil {
+			return nil, fmt.Errorf("faild to convert node prototype: %w", err)
+		}
+		node = pbBuilder.Build()
+	}
+
+	nd, ok := bindnode.Unwrap(node).(*ProofBundle)
+	if !ok || nd == nil {
+		return nil, fmt.Errorf("unwrapped node does not match schema.ProofBundle")
+	}
+	return nd, nil
+}
+
+func (mp *MultiProof) ToNode() (nd ipld.Node, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = toError(r)
+		}
+	}()
+	nd = bindnode.Wrap(mp, MultiProofPrototype.Type()).Representation()
+	return
+}
+
+func UnwrapMultiProof(node ipld.Node) (*MultiProof, error) {
+	if node.Prototype() != MultiProofPrototype {
+		mpBuilder := MultiProofPrototype.NewBuilder()
+		err := mpBuilder.AssignNode(node)
+		if err != nil {
+			return nil, fmt.Errorf("faild to convert node prototype: %w", err)
+		}
+		node = mpBuilder.Build()
+	}
+
+	nd, ok := bindnode.Unwrap(node).(*MultiProof)
+	if !ok || nd == nil {
+		return nil, fmt.Errorf("unwrapped node does not match schema.MultiProof")
+	}
+	return nd, nil
+}
@@ -0,0 +1,38 @@
+package tree
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// BytesCompare is the indicator of bytes.Compare, it's the key order of trees whose config has no compare function
+const BytesCompare uint64 = 0
+
+var (
+	compareFuncsLock sync.RWMutex
+	compareFuncs     = map[uint64]CompareFunc{
+		BytesCompare: bytes.Compare,
+	}
+)
+
+// RegisterCompareFunc registers the key order with the indicator, like codecs registered in go-ipld-prime/multicodec.
+// The indicator is persisted in TreeConfig, so the same function must be registered with the same indicator wherever
+// the tree is loaded. Use the private use range of multicodec(0x300000-0x3fffff) for custom orders.
+// The function must be a total order of keys, it returns 0 only if two keys are equal in bytes.
+func RegisterCompareFunc(indicator uint64, cp CompareFunc) {
+	compareFuncsLock.Lock()
+	defer compareFuncsLock.Unlock()
+	compareFuncs[indicator] = cp
+}
+
+// LookupCompareFunc returns the key order registered with the indicator
+func LookupCompareFunc(indicator uint64) (CompareFunc, error) {
+	compareFuncsLock.RLock()
+	defer compareFuncsLock.RUnlock()
+	cp, ok := compareFuncs[indicator]
+	if !ok {
+		return nil, fmt.Errorf("no compare function registered for indicator 0x%x", indicator)
+	}
+	return cp, nil
+}
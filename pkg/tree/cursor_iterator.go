@@ -0,0 +1,122 @@
+package tree
+
+import (
+	"github.com/ipld/go-ipld-prime"
+)
+
+// CursorIterator iterates pairs in [start, end] by moving the cursor on the caller's goroutine, it has no goroutine or
+// channel and is cheaper than Iterator for short scans. Use ProllyTree.Search to prefetch pairs in another goroutine.
+//
+// The iterator is positioned before the first pair after creation, call Next or Seek before reading Key and Value.
+type CursorIterator struct {
+	root  *ProllyNode
+	ns    NodeStore
+	cp    CompareFunc
+	start []byte
+	end   []byte
+	opts  *searchOptions
+
+	cur     *Cursor
+	started bool
+	closed  bool
+	err     error
+}
+
+// NewCursorIterator returns the iterator of pairs in [start, end], nil start or end means the range is unbounded on
+// that side. It takes the same options as Search, e.g. WithExclusiveEnd.
+func (pt *ProllyTree) NewCursorIterator(start []byte, end []byte, opts ...SearchOption) *CursorIterator {
+	return &CursorIterator{
+		root:  &pt.root,
+		ns:    pt.ns,
+		cp:    pt.compareFunc,
+		start: start,
+		end:   end,
+		opts:  newSearchOptions(opts),
+	}
+}
+
+// Seek moves the iterator to the first pair not smaller than the key(and start), it returns whether the iterator is
+// at a pair in range
+func (it *CursorIterator) Seek(key []byte) bool {
+	if it.closed || it.err != nil {
+		return false
+	}
+	if it.start != nil && (key == nil || it.cp(key, it.start) < 0) {
+		key = it.start
+	}
+	it.started = true
+
+	if key == nil {
+		if it.root.IsEmpty() {
+			it.cur = nil
+			return false
+		}
+		it.cur, it.err = CursorAtIndex(it.root, 0, it.ns)
+		return it.Valid()
+	}
+
+	it.cur, it.err = CursorAtItem(it.root, key, it.cp, it.ns)
+	if it.err != nil {
+		return false
+	}
+	// the key is bigger than all keys in the tree
+	if it.cur.IsValid() && it.cp(it.cur.GetKey(), key) < 0 {
+		it.err = it.cur.Advance()
+	}
+	return it.Valid()
+}
+
+// Next moves the iterator to the next pair, the first call moves it to the first pair in range. It returns whether
+// the iterator is at a pair in range.
+func (it *CursorIterator) Next() bool {
+	if it.closed || it.err != nil {
+		return false
+	}
+	if !it.started {
+		return it.Seek(it.start)
+	}
+	if it.cur == nil || !it.cur.IsValid() {
+		return false
+	}
+	it.err = it.cur.Advance()
+	return it.Valid()
+}
+
+// Valid returns whether the iterator is at a pair in range
+func (it *CursorIterator) Valid() bool {
+	if it.closed || it.err != nil || it.cur == nil || !it.cur.IsValid() {
+		return false
+	}
+	if it.end != nil && !it.opts.beforeEnd(it.cur.GetKey(), it.end, it.cp) {
+		return false
+	}
+	return true
+}
+
+// Key returns the key of current pair, nil if the iterator is not at a pair in range
+func (it *CursorIterator) Key() []byte {
+	if !it.Valid() {
+		return nil
+	}
+	return it.cur.GetKey()
+}
+
+// Value returns the value of current pair, nil if the iterator is not at a pair in range
+func (it *CursorIterator) Value() ipld.Node {
+	if !it.Valid() {
+		return nil
+	}
+	return it.cur.GetValue()
+}
+
+// Err returns the error happened while moving the iterator
+func (it *CursorIterator) Err() error {
+	return it.err
+}
+
+// Close releases the cursor, the iterator can not be used after closing. Closing it again is a no-op.
+func (it *CursorIterator) Close() error {
+	it.closed = true
+	it.cur = nil
+	return nil
+}
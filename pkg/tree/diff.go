@@ -0,0 +1,201 @@
+package tree
+
+import (
+	"context"
+	"github.com/ipld/go-ipld-prime"
+)
+
+// forEachChange calls fn with every key whose value differs between base and other in ascending order, old is nil
+// for keys only in other and new is nil for keys only in base.
+//
+// Both trees are walked from the root, subtrees with the same cid on both sides are skipped without being read, so
+// the cost depends on the size of the changes rather than the size of the trees.
+func forEachChange(ctx context.Context, base *ProllyTree, other *ProllyTree, fn func(key []byte, old ipld.Node, new ipld.Node) error) error {
+	return walkDiff(ctx, base, other, &diffVisitor{change: fn})
+}
+
+// diffVisitor receives the events while walking two trees, only change is required
+type diffVisitor struct {
+	// change is called with every changed key
+	change func(key []byte, old ipld.Node, new ipld.Node) error
+	// same is called when common pairs or subtrees are skipped
+	same func()
+	// onlySubtree is called with the subtree in one side when the next key of the other side is beyond its last key or
+	// the other side is exhausted, all pairs in it are added or removed. It returns true if the subtree is consumed
+	// without visiting its pairs.
+	onlySubtree func(inBase bool, lastKey []byte, count uint32) bool
+}
+
+func walkDiff(ctx context.Context, base *ProllyTree, other *ProllyTree, v *diffVisitor) error {
+	if base.Root.Equals(other.Root) {
+		return nil
+	}
+	baseSide, err := newDiffSide(base)
+	if err != nil {
+		return err
+	}
+	otherSide, err := newDiffSide(other)
+	if err != nil {
+		return err
+	}
+
+	// beyond returns whether the next key of the side is known to be after key, which is only the case if the side is
+	// exhausted or its next entry is a pair. The first key of a subtree is unknown until it is expanded.
+	beyond := func(f *diffFrame, key []byte) bool {
+		return f == nil || f.level == 0 && base.compareFunc(f.key(), key) > 0
+	}
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		fb, fo := baseSide.top(), otherSide.top()
+
+		switch {
+		case fb == nil && fo == nil:
+			return nil
+		// subtrees without any key of the other side inside
+		case fb != nil && fb.level > 0 && v.onlySubtree != nil && beyond(fo, fb.key()) &&
+			v.onlySubtree(true, fb.key(), fb.count()):
+			fb.idx++
+		case fo != nil && fo.level > 0 && v.onlySubtree != nil && beyond(fb, fo.key()) &&
+			v.onlySubtree(false, fo.key(), fo.count()):
+			fo.idx++
+		// expand the subtree in higher level until both sides are in the same level
+		case fb != nil && fb.level > 0 && (fo == nil || fb.level > fo.level):
+			err = baseSide.expand(ctx)
+		case fo != nil && fo.level > 0 && (fb == nil || fo.level > fb.level):
+			err = otherSide.expand(ctx)
+		// subtrees in the same level
+		case fb != nil && fo != nil && fb.level > 0:
+			if fb.node.GetIdxLink(fb.idx).Equals(fo.node.GetIdxLink(fo.idx)) {
+				if v.same != nil {
+					v.same()
+				}
+				fb.idx++
+				fo.idx++
+				continue
+			}
+			err = baseSide.expand(ctx)
+			if err != nil {
+				return err
+			}
+			err = otherSide.expand(ctx)
+		// pairs in leaf nodes
+		default:
+			cmp := 0
+			if fo == nil {
+				cmp = -1
+			} else if fb == nil {
+				cmp = 1
+			} else {
+				cmp = base.compareFunc(fb.key(), fo.key())
+			}
+
+			switch {
+			case cmp < 0:
+				err = v.change(fb.key(), fb.value(), nil)
+				fb.idx++
+			case cmp > 0:
+				err = v.change(fo.key(), nil, fo.value())
+				fo.idx++
+			default:
+				if !valueEqual(fb.value(), fo.value()) {
+					err = v.change(fo.key(), fb.value(), fo.value())
+				} else if v.same != nil {
+					v.same()
+				}
+				fb.idx++
+				fo.idx++
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// diffSide visits the entries of a tree from the root, the entries of a subtree are visited only if it is expanded
+type diffSide struct {
+	ns    NodeStore
+	stack []*diffFrame
+}
+
+// diffFrame is a node being visited, level is 0 for leaf nodes
+type diffFrame struct {
+	node  *ProllyNode
+	idx   int
+	level int
+}
+
+func newDiffSide(pt *ProllyTree) (*diffSide, error) {
+	side := &diffSide{ns: pt.ns}
+	if pt.root.IsEmpty() {
+		return side, nil
+	}
+	height, err := pt.height()
+	if err != nil {
+		return nil, err
+	}
+	side.stack = append(side.stack, &diffFrame{node: &pt.root, level: height - 1})
+	return side, nil
+}
+
+// top returns the frame of the next entry, nil if all entries have been visited
+func (ds *diffSide) top() *diffFrame {
+	for len(ds.stack) > 0 {
+		f := ds.stack[len(ds.stack)-1]
+		if f.idx < f.node.ItemCount() {
+			return f
+		}
+		ds.stack = ds.stack[:len(ds.stack)-1]
+	}
+	return nil
+}
+
+// expand replaces the next entry(a subtree) with the entries of the child node
+func (ds *diffSide) expand(ctx context.Context) error {
+	f := ds.top()
+	nd, err := ds.ns.ReadNode(ctx, f.node.GetIdxLink(f.idx))
+	if err != nil {
+		return err
+	}
+	f.idx++
+	ds.stack = append(ds.stack, &diffFrame{node: nd, level: f.level - 1})
+	return nil
+}
+
+func (f *diffFrame) key() []byte {
+	return f.node.GetIdxKey(f.idx)
+}
+
+func (f *diffFrame) value() ipld.Node {
+	return f.node.GetIdxValue(f.idx)
+}
+
+func (f *diffFrame) count() uint32 {
+	return f.node.GetIdxTreeCount(f.idx)
+}
+
+// height returns the number of levels of the tree
+func (pt *ProllyTree) height() (int, error) {
+	height := 1
+	n := &pt.root
+	var err error
+	for !n.IsLeaf {
+		n, err = pt.ns.ReadNode(context.Background(), n.GetIdxLink(0))
+		if err != nil {
+			return 0, err
+		}
+		height++
+	}
+	return height, nil
+}
+
+// valueEqual returns whether the values are the same, nil means the key is absent
+func valueEqual(a ipld.Node, b ipld.Node) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return ipld.DeepEqual(a, b)
+}
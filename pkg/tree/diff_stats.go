@@ -0,0 +1,81 @@
+package tree
+
+import (
+	"context"
+	"fmt"
+	"github.com/ipld/go-ipld-prime"
+)
+
+// KeyRange is the interval of keys in [Start, End]
+type KeyRange struct {
+	Start []byte
+	End   []byte
+}
+
+// DiffStats summarizes the changes between two trees
+type DiffStats struct {
+	Added    uint32
+	Removed  uint32
+	Modified uint32
+	// Ranges are the disjoint intervals covering all changed keys in ascending order, there is no unchanged key of
+	// either tree inside them
+	Ranges []KeyRange
+}
+
+// DiffStats counts the changes from the tree to the other tree and reports the changed key ranges without returning
+// every change. Common subtrees are skipped by cid, and subtrees without any key of the other tree inside, like a bulk
+// insert or a removed tail, are counted by SubtreeCount without being read.
+func (pt *ProllyTree) DiffStats(ctx context.Context, other *ProllyTree) (*DiffStats, error) {
+	otherConfig := other.TreeConfig()
+	config := pt.TreeConfig()
+	if !config.Equal(&otherConfig) {
+		return nil, fmt.Errorf("diff between trees with different config is not allowed")
+	}
+
+	stats := &DiffStats{}
+	// whether the last range is still open
+	extending := false
+	extend := func(key []byte) {
+		if extending {
+			stats.Ranges[len(stats.Ranges)-1].End = key
+			return
+		}
+		stats.Ranges = append(stats.Ranges, KeyRange{Start: key, End: key})
+		extending = true
+	}
+
+	err := walkDiff(ctx, pt, other, &diffVisitor{
+		change: func(key []byte, old ipld.Node, new ipld.Node) error {
+			switch {
+			case old == nil:
+				stats.Added++
+			case new == nil:
+				stats.Removed++
+			default:
+				stats.Modified++
+			}
+			extend(key)
+			return nil
+		},
+		same: func() {
+			extending = false
+		},
+		onlySubtree: func(inBase bool, lastKey []byte, count uint32) bool {
+			// the first key of the subtree is needed to open a new range
+			if !extending {
+				return false
+			}
+			if inBase {
+				stats.Removed += count
+			} else {
+				stats.Added += count
+			}
+			extend(lastKey)
+			return true
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -77,21 +77,25 @@ type LevelBuilder struct {
 	splitter      Splitter
 	framework     *Framework
 	done          bool
+	// level is 0 for leaf nodes and increases towards the root
+	level int
 }
 
 func newLevelBuilder(ctx context.Context, isLeaf bool, ns NodeStore, config *TreeConfig, frameWork *Framework) (*LevelBuilder, error) {
-	splitter := NewSplitterFromConfig(config)
+	levelCfg := config.levelConfig(isLeaf)
+	splitter := NewSplitterFromConfig(levelCfg)
 
 	nb := &nodeBuffer{
 		nd:          ProllyNode{IsLeaf: isLeaf},
 		nodeCoder:   frameWork.nodeCoder,
-		maxNodeSize: config.MaxNodeSize,
-		minNodeSize: config.MinNodeSize,
+		maxNodeSize: levelCfg.MaxNodeSize,
+		minNodeSize: levelCfg.MinNodeSize,
 	}
 
 	lb := &LevelBuilder{
 		config:     config,
 		isLeaf:     isLeaf,
+		level:      len(frameWork.builders),
 		nodeBuffer: nb,
 		nodeStore:  ns,
 		nodeCoder:  frameWork.nodeCoder,
@@ -119,7 +123,8 @@ func newLevelBuilderWithCursor(ctx context.Context, isLeaf bool, ns NodeStore, c
 		return nil, err
 	}
 
-	if cur.parent != nil {
+	// the parent builder may have been created already if a boundary was generated while appending entries
+	if cur.parent != nil && lb.parentBuilder == nil {
 		err = lb.createParentLevelBuilder(ctx)
 		if err != nil {
 			return nil, err
@@ -134,9 +139,18 @@ func (lb *LevelBuilder) append(ctx context.Context, key []byte, value ipld.Node,
 		return false, fmt.Errorf("append pair in done builder")
 	}
 
-	valBytes, err := lb.nodeCoder.EncodeNode(value)
-	if err != nil {
-		return false, err
+	// values are not fed to the splitter in key-only chunking, so updating them never moves boundaries. Keys of branch
+	// nodes are the boundary keys of their children, the level is fed with them so their boundaries are not decided by
+	// the same hashes again.
+	var valBytes []byte
+	var err error
+	if !lb.config.keyOnlyChunking() {
+		valBytes, err = lb.nodeCoder.EncodeNode(value)
+		if err != nil {
+			return false, err
+		}
+	} else if !lb.isLeaf {
+		valBytes = []byte{byte(lb.level)}
 	}
 
 	ok := lb.nodeBuffer.tryAddPair(key, value, subtreeCount)
@@ -351,7 +365,9 @@ type Framework struct {
 	cidPrefix *cid.Prefix
 	nodeCoder *NodeCoder
 	configCid cid.Cid
-	builders  []*LevelBuilder
+	// key order of the built tree
+	compareFunc CompareFunc
+	builders    []*LevelBuilder
 }
 
 func NewFramework(ctx context.Context, ns NodeStore, cfg *TreeConfig, cur *Cursor) (*Framework, error,
@@ -359,6 +375,13 @@ func NewFramework(ctx context.Context, ns NodeStore, cfg *TreeConfig, cur *Curso
 	if cfg == nil {
 		return nil, fmt.Errorf("nil config")
 	}
+	cp, err := cfg.CompareFunc()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Branch != nil && cfg.Branch.Strategy != nil && !cfg.Branch.Strategy.hasConfig(cfg.StrategyType) {
+		return nil, fmt.Errorf("branch strategy doesn't match the strategy type %v", cfg.StrategyType)
+	}
 	cidprefix := cfg.CidPrefix()
 	nodeCoder := NewNodeCoder()
 	// ignore error, we can register the Codec later
@@ -370,9 +393,10 @@ func NewFramework(ctx context.Context, ns NodeStore, cfg *TreeConfig, cur *Curso
 	}
 
 	framework := &Framework{
-		configCid: configCid,
-		cidPrefix: cidprefix,
-		nodeCoder: nodeCoder,
+		configCid:   configCid,
+		compareFunc: cp,
+		cidPrefix:   cidprefix,
+		nodeCoder:   nodeCoder,
 	}
 
 	if cur == nil {
@@ -491,6 +515,7 @@ func (fw *Framework) BuildTree(ctx context.Context) (*ProllyTree, cid.Cid, error
 	prollyTree.root = *rootNode
 	prollyTree.ns = fw.builders[0].nodeStore
 	prollyTree.treeConfig = *fw.builders[0].config
+	prollyTree.compareFunc = fw.compareFunc
 
 	treeCid, err := fw.builders[0].nodeStore.WriteTree(ctx, prollyTree, nil)
 	if err != nil {
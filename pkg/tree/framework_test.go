@@ -0,0 +1,42 @@
+package tree
+
+import (
+	"context"
+	"fmt"
+	"github.com/ipld/go-ipld-prime"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/zeebo/assert"
+	"testing"
+)
+
+func TestRebuildWithBoundaryBeforeCursor(t *testing.T) {
+	ctx := context.Background()
+	testKeys := make([][]byte, 3000)
+	testVals := make([]ipld.Node, 3000)
+	for i := range testKeys {
+		testKeys[i] = []byte(fmt.Sprintf("key-%06d", i))
+		testVals[i] = basicnode.NewString(fmt.Sprintf("value-%06d", i))
+	}
+	original, treeCid := BuildTestTreeFromData(t, testKeys, testVals)
+
+	// a boundary is generated while appending the pairs before the key in its leaf again, which creates the parent
+	// builder before the leaf builder is initialized
+	tree, err := LoadProllyTreeFromRootCid(treeCid, original.ns)
+	assert.NoError(t, err)
+	assert.NoError(t, tree.Mutate())
+	newVal := basicnode.NewString("updated")
+	assert.NoError(t, tree.Put(ctx, testKeys[1388], newVal))
+	_, err = tree.Rebuild(ctx)
+	assert.NoError(t, err)
+
+	assert.Equal(t, int(tree.TreeCount()), len(testKeys))
+	for i := range testKeys {
+		val, err := tree.Get(testKeys[i])
+		assert.NoError(t, err)
+		if i == 1388 {
+			assert.Equal(t, val, newVal)
+		} else {
+			assert.Equal(t, val, testVals[i])
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package tree
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/ipld/go-ipld-prime"
 	basicnode "github.com/ipld/go-ipld-prime/node/basic"
@@ -10,6 +12,10 @@ import (
 
 const DefaultChannelSize = 20
 
+var (
+	IteratorClosed = errors.New("iterator closed")
+)
+
 type pair struct {
 	key   []byte
 	value ipld.Node
@@ -22,51 +28,118 @@ func NewIterator(size int) *Iterator {
 		panic(fmt.Sprintf("invalid result channel size: %d", size))
 	}
 	return &Iterator{
-		result: make(chan pair, size),
+		result:  make(chan pair, size),
+		closing: make(chan struct{}),
 	}
 }
 
+// Iterator receives pairs from a producer goroutine. Errors of the producer, including ctx.Err() if the context is
+// canceled, are returned by NextPair once after all pairs before the error, and by Err after that.
 type Iterator struct {
-	mtx    sync.Mutex
-	result chan pair
-	done   bool
+	result    chan pair
+	closing   chan struct{}
+	closeOnce sync.Once
+	// written by the producer before closing result
+	err error
+
+	// the following fields are only used by the consumer
+	peeked       *pair
+	finished     bool
+	errDelivered bool
+	closed       bool
 }
 
-func (si *Iterator) receivePair(key []byte, value ipld.Node) {
-	if si.done {
-		panic("channel has closed")
-	}
-	si.result <- pair{
-		key:   key,
-		value: value,
+// receivePair sends the pair to the consumer, it returns error if the iterator is closed or the ctx is canceled
+func (si *Iterator) receivePair(ctx context.Context, key []byte, value ipld.Node) error {
+	select {
+	case si.result <- pair{key: key, value: value}:
+		return nil
+	case <-si.closing:
+		return IteratorClosed
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
-func (si *Iterator) finish() {
-	if si.done == true {
-		panic("repeated closing")
-	}
-	si.done = true
+// finish is called by the producer when it stops, err is nil if all pairs have been sent
+func (si *Iterator) finish(err error) {
+	si.err = err
 	close(si.result)
 }
 
+// peek waits for the next pair and keeps it until NextPair is called, it returns false if the producer finished
+func (si *Iterator) peek() bool {
+	if si.peeked != nil {
+		return true
+	}
+	if si.finished || si.closed {
+		return false
+	}
+	res, ok := <-si.result
+	if !ok {
+		si.finished = true
+		return false
+	}
+	si.peeked = &res
+	return true
+}
+
 func (si *Iterator) Next() (ipld.Node, ipld.Node, error) {
 	k, v, err := si.NextPair()
-	return basicnode.NewString(string(k)), v, err
+	if err != nil {
+		return nil, nil, err
+	}
+	return basicnode.NewString(string(k)), v, nil
 }
 
+// NextPair returns the next pair, io.EOF if all pairs have been returned or the error which stopped the producer
 func (si *Iterator) NextPair() ([]byte, ipld.Node, error) {
-	if si.Done() {
+	if !si.peek() {
+		if si.closed {
+			return nil, nil, io.EOF
+		}
+		if si.err != nil && !si.errDelivered {
+			si.errDelivered = true
+			return nil, nil, si.err
+		}
 		return nil, nil, io.EOF
 	}
-	res := <-si.result
+	res := si.peeked
+	si.peeked = nil
 	return res.key, res.value, nil
 }
 
+// Done waits until the next pair or the end of the producer, it returns false if the error of the producer has not
+// been returned by NextPair yet
 func (si *Iterator) Done() bool {
-	return si.done && len(si.result) == 0
+	if si.peek() {
+		return false
+	}
+	if si.closed {
+		return true
+	}
+	return si.err == nil || si.errDelivered
+}
+
+// Err returns the error which stopped the producer, it is nil before the producer finished or if all pairs have been
+// sent
+func (si *Iterator) Err() error {
+	if !si.finished || si.err == IteratorClosed {
+		return nil
+	}
+	return si.err
+}
+
+// Close stops the producer goroutine, the remaining pairs are dropped
+func (si *Iterator) Close() error {
+	si.closeOnce.Do(func() {
+		close(si.closing)
+	})
+	si.closed = true
+	si.peeked = nil
+	return nil
 }
 
 func (si *Iterator) IsEmpty() bool {
-	return len(si.result) == 0
+	return si.peeked == nil && len(si.result) == 0
 }
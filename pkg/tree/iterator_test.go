@@ -2,7 +2,12 @@ package tree
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/ipfs/go-cid"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
 	"github.com/zeebo/assert"
+	"io"
 	"testing"
 )
 
@@ -31,3 +36,244 @@ func TestSearchIterator(t *testing.T) {
 	}
 	assert.Equal(t, offset, 49996)
 }
+
+func TestSearchReverse(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(10000)
+
+	tree, _ := BuildTestTreeFromData(t, testKeys, testVals)
+
+	iter, err := tree.SearchReverse(ctx, testKeys[5], append(append([]byte{}, testKeys[9000]...), 0))
+	assert.NoError(t, err)
+	idx := 9000
+	for !iter.Done() {
+		k, v, err := iter.NextPair()
+		assert.NoError(t, err)
+		assert.Equal(t, k, testKeys[idx])
+		assert.Equal(t, v, testVals[idx])
+		idx--
+	}
+	assert.Equal(t, idx, 4)
+
+	iter, err = tree.SearchReverse(ctx, nil, testKeys[100])
+	assert.NoError(t, err)
+	idx = 100
+	for !iter.Done() {
+		k, _, err := iter.NextPair()
+		assert.NoError(t, err)
+		assert.Equal(t, k, testKeys[idx])
+		idx--
+	}
+	assert.Equal(t, idx, -1)
+}
+
+func TestCursorRetreatAfterEnd(t *testing.T) {
+	testKeys, testVals := RandomTestData(10000)
+	tree, _ := BuildTestTreeFromData(t, testKeys, testVals)
+	height, err := tree.height()
+	assert.NoError(t, err)
+	assert.True(t, height >= 3)
+
+	// advance past the end, then retreat over all pairs
+	cur, err := CursorAtIndex(&tree.root, 9990, tree.ns)
+	assert.NoError(t, err)
+	for cur.IsValid() {
+		assert.NoError(t, cur.Advance())
+	}
+	idx := 9999
+	for {
+		assert.NoError(t, cur.Retreat())
+		if !cur.IsValid() {
+			break
+		}
+		assert.Equal(t, cur.GetKey(), testKeys[idx])
+		idx--
+	}
+	assert.Equal(t, idx, -1)
+
+	// and advance again from before the start
+	for idx = 0; ; idx++ {
+		assert.NoError(t, cur.Advance())
+		if !cur.IsValid() {
+			break
+		}
+		assert.Equal(t, cur.GetKey(), testKeys[idx])
+	}
+	assert.Equal(t, idx, 10000)
+}
+
+type failingNodeStore struct {
+	NodeStore
+	reads int
+	limit int
+}
+
+func (ns *failingNodeStore) ReadNode(ctx context.Context, c cid.Cid) (*ProllyNode, error) {
+	ns.reads++
+	if ns.reads > ns.limit {
+		return nil, fmt.Errorf("failed to read node %s", c)
+	}
+	return ns.NodeStore.ReadNode(ctx, c)
+}
+
+func TestIteratorError(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(10000)
+	tree, _ := BuildTestTreeFromData(t, testKeys, testVals)
+
+	iter, err := tree.Search(ctx, testKeys[0], testKeys[9999])
+	assert.NoError(t, err)
+	assert.NoError(t, iter.Close())
+	// the producer closes the channel after stopping
+	for range iter.result {
+	}
+	_, _, err = iter.NextPair()
+	assert.Equal(t, err, io.EOF)
+	assert.NoError(t, iter.Err())
+
+	// the cursor is created before replacing the node store, so only reads while iterating fail
+	tree.ns = &failingNodeStore{NodeStore: tree.ns, limit: 3}
+	iter, err = tree.Search(ctx, testKeys[0], testKeys[9999])
+	assert.NoError(t, err)
+	num := 0
+	for !iter.Done() {
+		_, _, err = iter.NextPair()
+		if err != nil {
+			break
+		}
+		num++
+	}
+	assert.Error(t, err)
+	assert.True(t, num < 10000)
+	assert.Equal(t, iter.Err(), err)
+	assert.True(t, iter.Done())
+	_, _, err = iter.NextPair()
+	assert.Equal(t, err, io.EOF)
+
+	tree.ns = tree.ns.(*failingNodeStore).NodeStore
+	cctx, cancel := context.WithCancel(ctx)
+	iter, err = tree.Search(cctx, testKeys[0], testKeys[9999])
+	assert.NoError(t, err)
+	_, _, err = iter.NextPair()
+	assert.NoError(t, err)
+	cancel()
+	for {
+		_, _, err = iter.NextPair()
+		if err != nil {
+			break
+		}
+	}
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, errors.Is(iter.Err(), context.Canceled))
+}
+
+func TestCursorIterator(t *testing.T) {
+	testKeys, testVals := RandomTestData(10000)
+	tree, _ := BuildTestTreeFromData(t, testKeys, testVals)
+
+	it := tree.NewCursorIterator(testKeys[5], testKeys[5000])
+	idx := 5
+	for it.Next() {
+		assert.Equal(t, it.Key(), testKeys[idx])
+		assert.Equal(t, it.Value(), testVals[idx])
+		idx++
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, idx, 5001)
+	assert.Nil(t, it.Key())
+
+	// seek in range, before the range and after the range
+	assert.True(t, it.Seek(append(append([]byte{}, testKeys[100]...), 0)))
+	assert.Equal(t, it.Key(), testKeys[101])
+	assert.True(t, it.Next())
+	assert.Equal(t, it.Key(), testKeys[102])
+	assert.True(t, it.Seek([]byte{}))
+	assert.Equal(t, it.Key(), testKeys[5])
+	assert.False(t, it.Seek(testKeys[5001]))
+	assert.NoError(t, it.Close())
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Close())
+
+	// the end is excluded like Search with the same option
+	it = tree.NewCursorIterator(testKeys[5], testKeys[5000], WithExclusiveEnd())
+	idx = 5
+	for it.Next() {
+		assert.Equal(t, it.Key(), testKeys[idx])
+		idx++
+	}
+	assert.Equal(t, idx, 5000)
+	assert.False(t, it.Seek(testKeys[5000]))
+	assert.NoError(t, it.Close())
+
+	// unbounded
+	it = tree.NewCursorIterator(nil, nil)
+	idx = 0
+	for it.Next() {
+		assert.Equal(t, it.Key(), testKeys[idx])
+		idx++
+	}
+	assert.Equal(t, idx, 10000)
+	assert.False(t, it.Seek(append(append([]byte{}, testKeys[9999]...), 0)))
+
+	// errors while moving
+	tree.ns = &failingNodeStore{NodeStore: tree.ns, limit: 3}
+	it = tree.NewCursorIterator(nil, nil)
+	for it.Next() {
+	}
+	assert.Error(t, it.Err())
+}
+
+func TestScanPrefix(t *testing.T) {
+	ctx := context.Background()
+	ns := TestMemNodeStore()
+	framework, err := NewFramework(ctx, ns, DefaultChunkConfig(), nil)
+	assert.NoError(t, err)
+
+	prefixes := []string{"order/", "user/", "user0", "\xff\xff"}
+	var keys [][]byte
+	for _, prefix := range prefixes {
+		for i := 0; i < 500; i++ {
+			keys = append(keys, []byte(fmt.Sprintf("%s%04d", prefix, i)))
+		}
+	}
+	for _, key := range keys {
+		assert.NoError(t, framework.Append(ctx, key, basicnode.NewBytes(key)))
+	}
+	tree, _, err := framework.BuildTree(ctx)
+	assert.NoError(t, err)
+
+	for n, prefix := range prefixes {
+		iter, err := tree.ScanPrefix(ctx, []byte(prefix))
+		assert.NoError(t, err)
+		idx := n * 500
+		for !iter.Done() {
+			k, _, err := iter.NextPair()
+			assert.NoError(t, err)
+			assert.Equal(t, k, keys[idx])
+			idx++
+		}
+		assert.Equal(t, idx, (n+1)*500)
+	}
+
+	// half-open range excludes the end key
+	iter, err := tree.Search(ctx, keys[10], keys[20], WithExclusiveEnd())
+	assert.NoError(t, err)
+	num := 0
+	for !iter.Done() {
+		_, _, err := iter.NextPair()
+		assert.NoError(t, err)
+		num++
+	}
+	assert.Equal(t, num, 10)
+
+	iter, err = tree.SearchReverse(ctx, keys[10], keys[20], WithExclusiveEnd())
+	assert.NoError(t, err)
+	k, _, err := iter.NextPair()
+	assert.NoError(t, err)
+	assert.Equal(t, k, keys[19])
+
+	assert.Equal(t, PrefixEnd([]byte("user/")), []byte("user0"))
+	assert.Equal(t, PrefixEnd([]byte{1, 0xff, 0xff}), []byte{2})
+	assert.Nil(t, PrefixEnd([]byte{0xff}))
+	assert.Nil(t, PrefixEnd(nil))
+}
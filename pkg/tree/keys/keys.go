@@ -0,0 +1,251 @@
+// Package keys encodes tuples into keys whose bytes.Compare order matches the order of the tuples, so composite keys
+// can be stored in ProllyTree with the default key order.
+//
+// Every component starts with a tag byte of its kind. Tuples are compared component by component, components of
+// different kinds are ordered by their tags, and a tuple is smaller than the tuples it is a prefix of. Descending
+// components are encoded with every byte inverted.
+package keys
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/kenlabs/go-ipld-prolly-trees/pkg/tree"
+	"math"
+)
+
+type Kind byte
+
+const (
+	KindBytes  Kind = 0x01
+	KindString Kind = 0x02
+	KindInt    Kind = 0x03
+	KindUint   Kind = 0x04
+	KindFloat  Kind = 0x05
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindBytes:
+		return "bytes"
+	case KindString:
+		return "string"
+	case KindInt:
+		return "int"
+	case KindUint:
+		return "uint"
+	case KindFloat:
+		return "float"
+	default:
+		return fmt.Sprintf("unknown(0x%x)", byte(k))
+	}
+}
+
+// bytes and strings are terminated by terminator, zero bytes in them are escaped. The terminator is smaller than any
+// escaped or normal byte, and after inversion bigger than them, so both orders hold without knowing what follows.
+var (
+	terminator = []byte{0x00, 0x00}
+	escapedNul = []byte{0x00, 0x01}
+)
+
+// Component is an element of the tuple, Value is int64, uint64, float64, string or []byte according to Kind
+type Component struct {
+	Kind  Kind
+	Value interface{}
+	// Desc makes the component ordered in descending order
+	Desc bool
+}
+
+func Int(v int64) Component {
+	return Component{Kind: KindInt, Value: v}
+}
+
+func Uint(v uint64) Component {
+	return Component{Kind: KindUint, Value: v}
+}
+
+func Float(v float64) Component {
+	return Component{Kind: KindFloat, Value: v}
+}
+
+func String(v string) Component {
+	return Component{Kind: KindString, Value: v}
+}
+
+func Bytes(v []byte) Component {
+	return Component{Kind: KindBytes, Value: v}
+}
+
+// Desc returns the component ordered in descending order
+func Desc(c Component) Component {
+	c.Desc = true
+	return c
+}
+
+// Tuple is a composite key
+type Tuple []Component
+
+// Encode returns the key of the tuple, it panics if a component has wrong type of value
+func (t Tuple) Encode() []byte {
+	buf := make([]byte, 0, 16)
+	for _, c := range t {
+		buf = c.appendTo(buf)
+	}
+	return buf
+}
+
+// Encode returns the key of the tuple consisting of the components
+func Encode(components ...Component) []byte {
+	return Tuple(components).Encode()
+}
+
+func (c Component) appendTo(buf []byte) []byte {
+	start := len(buf)
+	buf = append(buf, byte(c.Kind))
+	switch c.Kind {
+	case KindInt:
+		// flip the sign bit so negative numbers are smaller
+		buf = appendUint64(buf, uint64(c.Value.(int64))^(1<<63))
+	case KindUint:
+		buf = appendUint64(buf, c.Value.(uint64))
+	case KindFloat:
+		bits := math.Float64bits(c.Value.(float64))
+		if bits&(1<<63) != 0 {
+			// negative numbers with bigger magnitude are smaller
+			bits = ^bits
+		} else {
+			bits |= 1 << 63
+		}
+		buf = appendUint64(buf, bits)
+	case KindString:
+		buf = appendEscaped(buf, []byte(c.Value.(string)))
+	case KindBytes:
+		buf = appendEscaped(buf, c.Value.([]byte))
+	default:
+		panic(fmt.Errorf("invalid kind of component: %v", c.Kind))
+	}
+	if c.Desc {
+		for i := start; i < len(buf); i++ {
+			buf[i] = ^buf[i]
+		}
+	}
+	return buf
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var raw [8]byte
+	binary.BigEndian.PutUint64(raw[:], v)
+	return append(buf, raw[:]...)
+}
+
+func appendEscaped(buf []byte, data []byte) []byte {
+	for _, b := range data {
+		if b == 0x00 {
+			buf = append(buf, escapedNul...)
+		} else {
+			buf = append(buf, b)
+		}
+	}
+	return append(buf, terminator...)
+}
+
+// Decode returns the tuple encoded in the key
+func Decode(key []byte) (Tuple, error) {
+	var t Tuple
+	for len(key) > 0 {
+		c, n, err := decodeComponent(key)
+		if err != nil {
+			return nil, err
+		}
+		t = append(t, c)
+		key = key[n:]
+	}
+	return t, nil
+}
+
+// decodeComponent decodes the first component of the key and returns the number of bytes it takes
+func decodeComponent(key []byte) (Component, int, error) {
+	var c Component
+	tag := key[0]
+	// the tags of descending components are inverted
+	if tag >= 0x80 {
+		c.Desc = true
+		tag = ^tag
+	}
+	c.Kind = Kind(tag)
+	at := func(i int) byte {
+		if c.Desc {
+			return ^key[i]
+		}
+		return key[i]
+	}
+
+	switch c.Kind {
+	case KindInt, KindUint, KindFloat:
+		if len(key) < 9 {
+			return c, 0, fmt.Errorf("truncated %v component", c.Kind)
+		}
+		var raw [8]byte
+		for i := range raw {
+			raw[i] = at(i + 1)
+		}
+		bits := binary.BigEndian.Uint64(raw[:])
+		switch c.Kind {
+		case KindInt:
+			c.Value = int64(bits ^ (1 << 63))
+		case KindUint:
+			c.Value = bits
+		default:
+			if bits&(1<<63) != 0 {
+				bits &^= 1 << 63
+			} else {
+				bits = ^bits
+			}
+			c.Value = math.Float64frombits(bits)
+		}
+		return c, 9, nil
+	case KindString, KindBytes:
+		var data []byte
+		for i := 1; ; i++ {
+			if i+1 >= len(key) {
+				return c, 0, fmt.Errorf("unterminated %v component", c.Kind)
+			}
+			b := at(i)
+			if b != 0x00 {
+				data = append(data, b)
+				continue
+			}
+			switch at(i + 1) {
+			case terminator[1]:
+				if c.Kind == KindString {
+					c.Value = string(data)
+				} else {
+					if data == nil {
+						data = []byte{}
+					}
+					c.Value = data
+				}
+				return c, i + 2, nil
+			case escapedNul[1]:
+				data = append(data, 0x00)
+				i++
+			default:
+				return c, 0, fmt.Errorf("invalid escape in %v component", c.Kind)
+			}
+		}
+	default:
+		return c, 0, fmt.Errorf("invalid tag of component: 0x%x", key[0])
+	}
+}
+
+// RangeBounds returns the bounds for ProllyTree.Search of all keys whose leading components are between start and
+// end(both inclusive), the search must be used with tree.WithExclusiveEnd. For descending components start and end
+// are in the order of keys, i.e. start has the bigger value. The end bound is nil if the range is unbounded above.
+func RangeBounds(start Tuple, end Tuple) ([]byte, []byte) {
+	return start.Encode(), tree.PrefixEnd(end.Encode())
+}
+
+// PrefixBounds returns the bounds for ProllyTree.Search of all keys starting with the prefix tuple, the search must be
+// used with tree.WithExclusiveEnd
+func PrefixBounds(prefix ...Component) ([]byte, []byte) {
+	return RangeBounds(prefix, prefix)
+}
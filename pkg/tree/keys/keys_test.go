@@ -0,0 +1,188 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"github.com/ipld/go-ipld-prime"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/kenlabs/go-ipld-prolly-trees/pkg/tree"
+	"github.com/zeebo/assert"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var testRand = rand.New(rand.NewSource(1))
+
+func randomTuple() Tuple {
+	tenants := []string{"", "a", "a\x00", "a\x00b", "ab", "b", "\xff"}
+	ints := []int64{math.MinInt64, -1000, -1, 0, 1, 1000, math.MaxInt64}
+	floats := []float64{math.Inf(-1), -1.5, -0.25, 0, 0.25, 1.5, math.Inf(1)}
+	data := make([]byte, testRand.Intn(3))
+	testRand.Read(data)
+	for i := range data {
+		// more zero bytes to test escaping
+		if testRand.Intn(2) == 0 {
+			data[i] = 0
+		}
+	}
+	return Tuple{
+		String(tenants[testRand.Intn(len(tenants))]),
+		Desc(Int(ints[testRand.Intn(len(ints))])),
+		Float(floats[testRand.Intn(len(floats))]),
+		Bytes(data),
+		Desc(String(tenants[testRand.Intn(len(tenants))])),
+		Uint(uint64(testRand.Intn(3))),
+	}
+}
+
+// compareTuples compares tuples logically, the tuples have the same kinds of components
+func compareTuples(a, b Tuple) int {
+	for i := range a {
+		var cmp int
+		switch a[i].Kind {
+		case KindString:
+			cmp = strings.Compare(a[i].Value.(string), b[i].Value.(string))
+		case KindBytes:
+			cmp = bytes.Compare(a[i].Value.([]byte), b[i].Value.([]byte))
+		case KindInt:
+			x, y := a[i].Value.(int64), b[i].Value.(int64)
+			if x < y {
+				cmp = -1
+			} else if x > y {
+				cmp = 1
+			}
+		case KindUint:
+			x, y := a[i].Value.(uint64), b[i].Value.(uint64)
+			if x < y {
+				cmp = -1
+			} else if x > y {
+				cmp = 1
+			}
+		case KindFloat:
+			x, y := a[i].Value.(float64), b[i].Value.(float64)
+			if x < y {
+				cmp = -1
+			} else if x > y {
+				cmp = 1
+			}
+		}
+		if a[i].Desc {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+func TestOrder(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		a, b := randomTuple(), randomTuple()
+		cmp := bytes.Compare(a.Encode(), b.Encode())
+		assert.Equal(t, cmp, compareTuples(a, b))
+	}
+
+	// a tuple is smaller than the tuples it is a prefix of
+	ordered := [][]byte{
+		Encode(String("a")),
+		Encode(String("a"), Int(-1)),
+		Encode(String("a"), Int(0)),
+		Encode(String("a\x00")),
+		Encode(String("a\x00\x00")),
+		Encode(String("a\x01")),
+		Encode(String("ab"), Desc(String("b"))),
+		Encode(String("ab"), Desc(String("a"))),
+		Encode(String("ab"), Desc(String(""))),
+		Encode(String("ab"), Desc(String("")), Int(0)),
+	}
+	for i := 1; i < len(ordered); i++ {
+		assert.True(t, bytes.Compare(ordered[i-1], ordered[i]) < 0)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		tp := randomTuple()
+		decoded, err := Decode(tp.Encode())
+		assert.NoError(t, err)
+		assert.DeepEqual(t, decoded, tp)
+	}
+
+	empty, err := Decode(Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, len(empty), 0)
+
+	_, err = Decode([]byte{byte(KindInt), 0, 1})
+	assert.Error(t, err)
+	_, err = Decode([]byte{byte(KindString), 'a', 0})
+	assert.Error(t, err)
+	_, err = Decode([]byte{0x7f})
+	assert.Error(t, err)
+}
+
+func TestSearchBounds(t *testing.T) {
+	ctx := context.Background()
+	var tuples []Tuple
+	for _, tenant := range []string{"a", "a\x00", "b"} {
+		for ts := int64(-5); ts < 5; ts++ {
+			for id := uint64(0); id < 3; id++ {
+				tuples = append(tuples, Tuple{String(tenant), Desc(Int(ts)), Uint(id)})
+			}
+		}
+	}
+	sort.Slice(tuples, func(i, j int) bool {
+		return compareTuples(tuples[i], tuples[j]) < 0
+	})
+	keys := make([][]byte, len(tuples))
+	vals := make([]ipld.Node, len(tuples))
+	for i := range tuples {
+		keys[i] = tuples[i].Encode()
+		vals[i] = basicnode.NewInt(int64(i))
+	}
+
+	ns := tree.TestMemNodeStore()
+	fw, err := tree.NewFramework(ctx, ns, tree.DefaultChunkConfig(), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, fw.AppendBatch(ctx, keys, vals))
+	pt, _, err := fw.BuildTree(ctx)
+	assert.NoError(t, err)
+
+	search := func(start, end []byte) []int64 {
+		iter, err := pt.Search(ctx, start, end, tree.WithExclusiveEnd())
+		assert.NoError(t, err)
+		var res []int64
+		for !iter.Done() {
+			_, v, err := iter.NextPair()
+			assert.NoError(t, err)
+			i, err := v.AsInt()
+			assert.NoError(t, err)
+			res = append(res, i)
+		}
+		return res
+	}
+
+	// tenant "a" only, "a\x00" is not included
+	res := search(PrefixBounds(String("a")))
+	assert.Equal(t, len(res), 30)
+	for i, v := range res {
+		assert.Equal(t, v, int64(i))
+	}
+
+	// tenant "b" from timestamp 3 down to -2
+	res = search(RangeBounds(Tuple{String("b"), Desc(Int(3))}, Tuple{String("b"), Desc(Int(-2))}))
+	assert.Equal(t, len(res), 18)
+	for i, v := range res {
+		tp := tuples[v]
+		assert.Equal(t, tp[0].Value, "b")
+		assert.Equal(t, tp[1].Value, int64(3-i/3))
+	}
+
+	// the empty prefix covers all keys
+	start, end := PrefixBounds()
+	assert.Equal(t, len(start), 0)
+	assert.Nil(t, end)
+}
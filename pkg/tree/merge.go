@@ -0,0 +1,93 @@
+package tree
+
+import (
+	"context"
+	"fmt"
+	"github.com/ipld/go-ipld-prime"
+)
+
+// Conflict is a key changed differently by both sides of a three-way merge, nil values mean the key is absent
+type Conflict struct {
+	Key    []byte
+	Base   ipld.Node
+	Ours   ipld.Node
+	Theirs ipld.Node
+}
+
+// ConflictResolver returns the merged value of the conflicting key, nil means removing the key. Returning error aborts
+// the merge.
+type ConflictResolver func(ctx context.Context, conflict *Conflict) (ipld.Node, error)
+
+// Merge3 merges the changes from base to theirs into the tree(ours), base should be the common ancestor of both sides.
+// Changes only made by one side, including removals, are applied automatically. Keys changed differently by both sides
+// are passed to the resolver, if the resolver is nil they keep the value of ours and are returned as conflicts.
+func (pt *ProllyTree) Merge3(ctx context.Context, base *ProllyTree, theirs *ProllyTree, resolver ConflictResolver) ([]*Conflict, error) {
+	if pt.mutating {
+		return nil, fmt.Errorf("Cannot merge while tree is being mutated. Apply changes with Rebuild first.")
+	}
+	config := pt.TreeConfig()
+	baseConfig := base.TreeConfig()
+	theirsConfig := theirs.TreeConfig()
+	if !config.Equal(&baseConfig) || !config.Equal(&theirsConfig) {
+		return nil, fmt.Errorf("merge between trees with different config is not allowed")
+	}
+
+	err := pt.Mutate()
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []*Conflict
+	err = forEachChange(ctx, base, theirs, func(key []byte, baseVal ipld.Node, theirsVal ipld.Node) error {
+		oursVal, err := pt.Get(key)
+		if err == KeyNotFound {
+			oursVal = nil
+		} else if err != nil {
+			return err
+		}
+
+		var merged ipld.Node
+		switch {
+		// only changed by theirs
+		case valueEqual(oursVal, baseVal):
+			merged = theirsVal
+		// the same change on both sides
+		case valueEqual(oursVal, theirsVal):
+			return nil
+		default:
+			conflict := &Conflict{
+				Key:    key,
+				Base:   baseVal,
+				Ours:   oursVal,
+				Theirs: theirsVal,
+			}
+			if resolver == nil {
+				conflicts = append(conflicts, conflict)
+				return nil
+			}
+			merged, err = resolver(ctx, conflict)
+			if err != nil {
+				return err
+			}
+			if valueEqual(oursVal, merged) {
+				return nil
+			}
+		}
+
+		if merged == nil {
+			return pt.Delete(ctx, key)
+		}
+		return pt.Put(ctx, key, merged)
+	})
+	if err != nil {
+		pt.mutating = false
+		pt.mutations = nil
+		return nil, err
+	}
+
+	_, err = pt.Rebuild(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
@@ -32,6 +32,38 @@ type TreeConfig struct {
 	//NodeCodec      NodeCodec
 	StrategyType byte
 	Strategy     strategy
+	// CompareFunction is the indicator of the key order registered by RegisterCompareFunc, nil means BytesCompare
+	CompareFunction *uint64
+	// KeyOnlyChunking makes boundaries only depend on keys, so updating values never moves boundaries unless the node
+	// exceeds MaxNodeSize. The splitter only counts the bytes of keys for MinNodeSize in the mode, set MaxNodeSize
+	// large enough for the values. nil means false.
+	KeyOnlyChunking *bool
+	// Branch overrides the chunking parameters for branch nodes, nil means branch nodes are chunked the same as leaf
+	// nodes
+	Branch *BranchConfig
+}
+
+// BranchConfig includes the chunking parameters of branch nodes. Branch pairs(key, link and count) are small and of
+// similar sizes, so branch nodes usually want a larger fan-out than leaf nodes for shallower trees.
+type BranchConfig struct {
+	MinNodeSize    int
+	MaxNodeSize    int
+	MaxPairsInNode int
+	// Strategy must be the config of TreeConfig.StrategyType, nil means the same as TreeConfig.Strategy
+	Strategy *strategy
+}
+
+func (bc *BranchConfig) Equal(another *BranchConfig, strategyType byte) bool {
+	if bc == nil || another == nil {
+		return bc == nil && another == nil
+	}
+	if bc.MinNodeSize != another.MinNodeSize ||
+		bc.MaxNodeSize != another.MaxNodeSize ||
+		bc.MaxPairsInNode != another.MaxPairsInNode ||
+		(bc.Strategy == nil) != (another.Strategy == nil) {
+		return false
+	}
+	return bc.Strategy == nil || bc.Strategy.Equal(another.Strategy, strategyType)
 }
 
 func (cfg *TreeConfig) CidPrefix() *cid.Prefix {
@@ -47,6 +79,54 @@ func (cfg *TreeConfig) CidPrefix() *cid.Prefix {
 	return prefix
 }
 
+// compareIndicator returns the indicator of the key order of the tree
+func (cfg *TreeConfig) compareIndicator() uint64 {
+	if cfg.CompareFunction == nil {
+		return BytesCompare
+	}
+	return *cfg.CompareFunction
+}
+
+func (cfg *TreeConfig) keyOnlyChunking() bool {
+	return cfg.KeyOnlyChunking != nil && *cfg.KeyOnlyChunking
+}
+
+// fillOptionalFields returns the config whose optional fields before the last present one are set to their defaults,
+// the tuple representation can't skip optional fields in the middle
+func (cfg *TreeConfig) fillOptionalFields() *TreeConfig {
+	filled := *cfg
+	if filled.Branch != nil && filled.KeyOnlyChunking == nil {
+		keyOnly := false
+		filled.KeyOnlyChunking = &keyOnly
+	}
+	if filled.KeyOnlyChunking != nil && filled.CompareFunction == nil {
+		indicator := BytesCompare
+		filled.CompareFunction = &indicator
+	}
+	return &filled
+}
+
+// levelConfig returns the config used to chunk leaf or branch nodes
+func (cfg *TreeConfig) levelConfig(isLeaf bool) *TreeConfig {
+	if isLeaf || cfg.Branch == nil {
+		return cfg
+	}
+	levelCfg := *cfg
+	levelCfg.MinNodeSize = cfg.Branch.MinNodeSize
+	levelCfg.MaxNodeSize = cfg.Branch.MaxNodeSize
+	levelCfg.MaxPairsInNode = cfg.Branch.MaxPairsInNode
+	if cfg.Branch.Strategy != nil {
+		levelCfg.Strategy = *cfg.Branch.Strategy
+	}
+	levelCfg.Branch = nil
+	return &levelCfg
+}
+
+// CompareFunc returns the key order of the tree, it returns error if the order is not registered
+func (cfg *TreeConfig) CompareFunc() (CompareFunc, error) {
+	return LookupCompareFunc(cfg.compareIndicator())
+}
+
 func (cfg *TreeConfig) Equal(another *TreeConfig) bool {
 	if cfg.StrategyType != another.StrategyType ||
 		cfg.MinNodeSize != another.MinNodeSize ||
@@ -54,7 +134,11 @@ func (cfg *TreeConfig) Equal(another *TreeConfig) bool {
 		cfg.CidVersion != another.CidVersion ||
 		cfg.Codec != another.Codec ||
 		cfg.HashFunction != another.HashFunction ||
-		cfg.HashLength != another.HashLength {
+		(cfg.HashLength == nil) != (another.HashLength == nil) ||
+		cfg.HashLength != nil && *cfg.HashLength != *another.HashLength ||
+		cfg.compareIndicator() != another.compareIndicator() ||
+		cfg.keyOnlyChunking() != another.keyOnlyChunking() ||
+		!cfg.Branch.Equal(another.Branch, cfg.StrategyType) {
 		return false
 	}
 	return cfg.Strategy.Equal(&another.Strategy, cfg.StrategyType)
@@ -77,40 +161,58 @@ func DefaultChunkConfig() *TreeConfig {
 	}
 }
 
+// strategy is the config of the splitter, only the field of StrategyType should be set
 type strategy struct {
-	//Weilbull    *WeibullThresholdConfig
-	//RollingHash *RollingHashConfig
-	Suffix *HashThresholdConfig
+	Suffix      *HashThresholdConfig
+	Weibull     *WeibullThresholdConfig
+	RollingHash *RollingHashConfig
 }
 
 func (sg *strategy) Equal(another *strategy, strategyType byte) bool {
 	var strCfg strategyConfig
 	var _strCfg strategyConfig
 	switch strategyType {
-	//case WeibullThreshold:
-	//	strCfg = sg.Weilbull
-	//	_strCfg = another.Weilbull
+	case WeibullThreshold:
+		strCfg = sg.Weibull
+		_strCfg = another.Weibull
 	case SuffixThreshold:
 		strCfg = sg.Suffix
 		_strCfg = another.Suffix
-	//case RollingHash:
-	//	strCfg = sg.RollingHash
-	//	_strCfg = another.RollingHash
+	case RollingHash:
+		strCfg = sg.RollingHash
+		_strCfg = another.RollingHash
 	default:
 		panic(fmt.Errorf("invalid strategy: %v", strategyType))
 	}
 	return strCfg.Equal(_strCfg)
 }
 
+// hasConfig returns whether the config of the strategy type is set
+func (sg *strategy) hasConfig(strategyType byte) bool {
+	switch strategyType {
+	case SuffixThreshold:
+		return sg.Suffix != nil
+	case WeibullThreshold:
+		return sg.Weibull != nil
+	case RollingHash:
+		return sg.RollingHash != nil
+	default:
+		return false
+	}
+}
+
 type strategyConfig interface {
 	Equal(sc strategyConfig) bool
 }
 
 var _ strategyConfig = &WeibullThresholdConfig{}
 
+// WeibullThresholdConfig is the config of WeibullSplitter, the size of nodes follows weibull distribution with shape K
+// and scale L(in bytes)
 type WeibullThresholdConfig struct {
-	K float64
-	L float64
+	K            float64
+	L            float64
+	HashFunction uint64
 }
 
 func (wtc *WeibullThresholdConfig) Equal(sc strategyConfig) bool {
@@ -118,7 +220,7 @@ func (wtc *WeibullThresholdConfig) Equal(sc strategyConfig) bool {
 	if !ok {
 		return false
 	}
-	if wtc.K == _wtc.K && wtc.L == _wtc.L {
+	if wtc.K == _wtc.K && wtc.L == _wtc.L && wtc.HashFunction == _wtc.HashFunction {
 		return true
 	}
 	return false
@@ -140,8 +242,11 @@ func (ptc *HashThresholdConfig) Equal(sc strategyConfig) bool {
 	return false
 }
 
+// RollingHashConfig is the config of RollingHashSplitter, boundaries are decided by the buzhash of the last
+// RollingHashWindow bytes of the serialized pairs. The expected node size is about 1<<ChunkingFactor bytes.
 type RollingHashConfig struct {
 	RollingHashWindow uint32
+	ChunkingFactor    int
 }
 
 func (rhc *RollingHashConfig) Equal(sc strategyConfig) bool {
@@ -149,7 +254,7 @@ func (rhc *RollingHashConfig) Equal(sc strategyConfig) bool {
 	if !ok {
 		return false
 	}
-	if rhc.RollingHashWindow == another.RollingHashWindow {
+	if rhc.RollingHashWindow == another.RollingHashWindow && rhc.ChunkingFactor == another.ChunkingFactor {
 		return true
 	}
 	return false
@@ -33,16 +33,12 @@ func (cur *Cursor) IsAtEnd() bool {
 	return cur.idx == cur.node.ItemCount()-1
 }
 
-func (cur *Cursor) isAtStart() bool {
-	return cur.idx == 0
-}
-
-func (cur *Cursor) IsBiggerThanTheNode(key []byte) bool {
+func (cur *Cursor) IsBiggerThanTheNode(key []byte, cp CompareFunc) bool {
 	// only call the function while cur at tail
 	if !cur.IsAtEnd() {
 		return false
 	}
-	return DefaultCompareFunc(key, cur.GetKey()) > 0
+	return cp(key, cur.GetKey()) > 0
 }
 
 func (cur *Cursor) GetLink() cid.Cid {
@@ -83,7 +79,54 @@ func CursorAtItem(root *ProllyNode, item []byte, cp CompareFunc, ns NodeStore) (
 	return cur, nil
 }
 
+// CursorAtIndex returns the cursor at the index-th pair(from 0) of the tree, it skips subtrees by SubtreeCount
+func CursorAtIndex(root *ProllyNode, index uint32, ns NodeStore) (*Cursor, error) {
+	if index >= root.totalPairCount() {
+		return nil, fmt.Errorf("index %d out of range", index)
+	}
+	var cur *Cursor
+	nd := root
+	for {
+		idx := 0
+		for idx < nd.ItemCount()-1 && index >= nd.GetIdxTreeCount(idx) {
+			index -= nd.GetIdxTreeCount(idx)
+			idx++
+		}
+		cur = &Cursor{
+			node:   nd,
+			idx:    idx,
+			ns:     ns,
+			parent: cur,
+		}
+		if nd.IsLeafNode() {
+			break
+		}
+
+		var err error
+		nd, err = ns.ReadNode(context.Background(), cur.GetLink())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cur, nil
+}
+
+// rank returns the number of pairs before the cursor, it sums the subtree counts before the cursor in every level
+func (cur *Cursor) rank() uint32 {
+	var res uint32
+	for c := cur; c != nil; c = c.parent {
+		for i := 0; i < c.idx && i < c.node.ItemCount(); i++ {
+			res += c.node.GetIdxTreeCount(i)
+		}
+	}
+	return res
+}
+
 func (cur *Cursor) Advance() error {
+	// retreated before the start, the parents are before the start too
+	if cur.idx < 0 {
+		return cur.reanchor(false)
+	}
 	l := cur.node.ItemCount()
 	if cur.idx < l-1 {
 		cur.idx++
@@ -113,6 +156,76 @@ func (cur *Cursor) Advance() error {
 	return nil
 }
 
+// Retreat moves the cursor to the previous pair like Advance does in reverse, it sets the cursor invalid(index -1)
+// if arriving the start
+func (cur *Cursor) Retreat() error {
+	// advanced past the end, the parents are past the end too
+	if cur.idx >= cur.node.ItemCount() {
+		return cur.reanchor(true)
+	}
+	if cur.idx > 0 {
+		cur.idx--
+		return nil
+	}
+	if cur.parent == nil {
+		cur.idx = -1
+		return nil
+	}
+	err := cur.parent.Retreat()
+	if err != nil {
+		return err
+	}
+	if !cur.parent.IsValid() {
+		cur.idx = -1
+		return nil
+	}
+
+	link := cur.parent.GetLink()
+	nd, err := cur.ns.ReadNode(context.Background(), link)
+	if err != nil {
+		return err
+	}
+
+	cur.node = nd
+	cur.idx = nd.ItemCount() - 1
+	return nil
+}
+
+// reanchor moves the invalid cursor back to the last(or first) pair, the invalid parents are moved back too and the
+// node is read from the parent like Advance does
+func (cur *Cursor) reanchor(toLast bool) error {
+	if cur.parent != nil && !cur.parent.IsValid() {
+		err := cur.parent.reanchor(toLast)
+		if err != nil {
+			return err
+		}
+		nd, err := cur.ns.ReadNode(context.Background(), cur.parent.GetLink())
+		if err != nil {
+			return err
+		}
+		cur.node = nd
+	}
+	if toLast {
+		cur.idx = cur.node.ItemCount() - 1
+	} else {
+		cur.idx = 0
+	}
+	return nil
+}
+
+// clone returns a deep copy of the cursor, moving the copy doesn't affect the origin cursor
+func (cur *Cursor) clone() *Cursor {
+	c := &Cursor{
+		node: cur.node,
+		idx:  cur.idx,
+		ns:   cur.ns,
+	}
+	if cur.parent != nil {
+		c.parent = cur.parent.clone()
+	}
+	return c
+}
+
 func (cur *Cursor) GetKey() []byte {
 	if !cur.IsValid() {
 		panic("get Key from invalid cursor")
@@ -169,101 +282,6 @@ func (cur *Cursor) copy(other *Cursor) {
 	}
 }
 
-func (cur *Cursor) SkipCommon(other *Cursor) error {
-	var err error
-	if !cur.equalKeyValuePair(other) {
-		return fmt.Errorf("must start from same key/value pair")
-	}
-
-	// if parents exist and equal, try skip in higher level
-	if cur.parent != nil && other.parent != nil && cur.parent.equalKeyValuePair(other.parent) {
-		err = cur.parent.SkipCommon(other.parent)
-		if err != nil {
-			return err
-		}
-
-		// the parents have skipped the common parts, now set the children cursor at right location
-
-		// set cursor in the end(invalid) if parent is invalid
-		if !cur.parent.IsValid() {
-			link := getCidFromIpldNode(cur.parent.node.GetIdxValue(cur.parent.node.ItemCount() - 1))
-			nd, err := cur.ns.ReadNode(context.Background(), link)
-			if err != nil {
-				return err
-			}
-			cur.node = nd
-			cur.idx = cur.node.ItemCount()
-		} else {
-			link := cur.parent.GetLink()
-			nd, err := cur.ns.ReadNode(context.Background(), link)
-			if err != nil {
-				return err
-			}
-			cur.node = nd
-			cur.idx = 0
-		}
-
-		// the same with beyond
-		if !other.parent.IsValid() {
-			link := getCidFromIpldNode(other.parent.node.GetIdxValue(other.parent.node.ItemCount() - 1))
-			nd, err := other.ns.ReadNode(context.Background(), link)
-			if err != nil {
-				return err
-			}
-			other.node = nd
-			other.idx = other.node.ItemCount()
-		} else {
-			link := other.parent.GetLink()
-			nd, err := other.ns.ReadNode(context.Background(), link)
-			if err != nil {
-				return err
-			}
-			other.node = nd
-			other.idx = 0
-		}
-	}
-
-	// can not skip in higher level, advance together until:
-	// 1. either or both cursors invalid, return
-	// 2. differ , return
-	// 3. both arrive the start of new nodes, try skip in higher level, not return
-	for {
-		err = cur.Advance()
-		if err != nil {
-			return err
-		}
-		err = other.Advance()
-		if err != nil {
-			return err
-		}
-		// either cursor arrives the end
-		if !cur.IsValid() || !other.IsValid() {
-			return nil
-		}
-		// cursors differ
-		if !cur.equalKeyValuePair(other) {
-			return nil
-		}
-		// try skip in higher level
-		if cur.isAtStart() && other.isAtStart() {
-			err = cur.SkipCommon(other)
-			if err != nil {
-				return err
-			}
-		}
-	}
-}
-
-func (cur *Cursor) equalKeyValuePair(other *Cursor) bool {
-	if !bytes.Equal(cur.GetKey(), other.GetKey()) {
-		return false
-	}
-	if !bytes.Equal(EncodeNode(cur.GetValue()), EncodeNode(other.GetValue())) {
-		return false
-	}
-	return true
-}
-
 func EncodeNode(n ipld.Node) []byte {
 	buf := bytes.Buffer{}
 	if err := dagcbor.Encode(n, &buf); err != nil {
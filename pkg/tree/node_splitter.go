@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/multiformats/go-multihash"
 	"hash"
+	"math"
+	"math/bits"
 )
 
 type Splitter interface {
@@ -38,6 +40,17 @@ func NewSplitterFromConfig(config *TreeConfig) Splitter {
 			hashFunction: hashFunction,
 			pattern:      uint32(1<<config.Strategy.Suffix.ChunkingFactor - 1),
 		}
+	case WeibullThreshold:
+		hashFunction, err := multihash.GetHasher(config.Strategy.Weibull.HashFunction)
+		if err != nil {
+			panic(err)
+		}
+		splitter = &WeibullSplitter{
+			config:       config,
+			hashFunction: hashFunction,
+		}
+	case RollingHash:
+		splitter = NewRollingHashSplitter(config)
 	default:
 		panic(fmt.Errorf("unsupported chunk strategy: %v", config.StrategyType))
 	}
@@ -73,6 +86,10 @@ func (p *SuffixSplitter) Append(key, val []byte) error {
 
 	// the maxNodeSize check is out of splitter and in append function
 
+	// the decision only depends on the key itself in key-only chunking
+	if p.config.keyOnlyChunking() {
+		p.hashFunction.Reset()
+	}
 	p.hashFunction.Write(input)
 	h := p.hashFunction.Sum(nil)
 
@@ -90,55 +107,179 @@ func (p *SuffixSplitter) Reset() {
 	p.isBoundary = false
 }
 
-//type WeibullSplitter struct {
-//	isBoundary     bool
-//	totalItemsSize int
-//	config         *TreeConfig
-//}
-//
-//func (ws *WeibullSplitter) IsBoundary() bool {
-//	return ws.isBoundary
-//}
-//
-//func (ws *WeibullSplitter) Append(Key, Val []byte) error {
-//	// can't append until reset splitter after boundary generated
-//	if ws.isBoundary {
-//		return fmt.Errorf("boundary generated but not reset")
-//	}
-//	// the function may be configurable
-//	hash := xxh3.Hash(append(Key, Val...))
-//
-//	itemSize := len(Key) + len(Val)
-//	ws.totalItemsSize += itemSize
-//
-//	// can not split
-//	if ws.totalItemsSize < ws.config.MinNodeSize {
-//		return nil
-//	}
-//	// must split
-//	if ws.totalItemsSize > ws.config.MaxNodeSize {
-//		ws.isBoundary = true
-//		return nil
-//	}
-//
-//	start := weibullCDF(ws.totalItemsSize-itemSize, ws.config.Strategy.Weilbull.K, ws.config.Strategy.Weilbull.L)
-//	end := weibullCDF(ws.totalItemsSize, ws.config.Strategy.Weilbull.K, ws.config.Strategy.Weilbull.L)
-//	p := float64(hash) / math.MaxUint32
-//	d := 1 - start
-//	target := (end - start) / d
-//	if p < target {
-//		ws.isBoundary = true
-//	}
-//
-//	return nil
-//}
-//
-//func (ws *WeibullSplitter) Reset() {
-//	ws.totalItemsSize = 0
-//	ws.isBoundary = false
-//}
-//
-//// -exp(-pow(x/L),K)
-//func weibullCDF(x int, K, L float64) float64 {
-//	return -math.Exp(-math.Pow(float64(x)/L, K))
-//}
+var _ Splitter = &WeibullSplitter{}
+
+// WeibullSplitter splits nodes with a threshold growing with the size of the node according to the CDF of weibull
+// distribution, so very small or very large nodes are unlikely
+type WeibullSplitter struct {
+	isBoundary       bool
+	totalBytesSize   int
+	totalPairsNumber int
+	hashFunction     hash.Hash
+	config           *TreeConfig
+}
+
+func (ws *WeibullSplitter) IsBoundary() bool {
+	return ws.isBoundary
+}
+
+func (ws *WeibullSplitter) Append(key, val []byte) error {
+	// can't append until reset splitter after boundary generated
+	if ws.isBoundary {
+		return fmt.Errorf("boundary generated but not reset")
+	}
+	input := append(append(make([]byte, 0, len(key)+len(val)), key...), val...)
+	inputSize := len(input)
+
+	ws.totalBytesSize += inputSize
+	ws.totalPairsNumber += 1
+
+	if ws.totalPairsNumber >= ws.config.MaxPairsInNode {
+		ws.isBoundary = true
+		return nil
+	}
+	if ws.totalBytesSize < ws.config.MinNodeSize {
+		return nil
+	}
+
+	// the decision only depends on the pair itself and the size of the node
+	ws.hashFunction.Reset()
+	ws.hashFunction.Write(input)
+	h := ws.hashFunction.Sum(nil)
+
+	// probability of splitting here given no split before
+	wcfg := ws.config.Strategy.Weibull
+	start := weibullCDF(ws.totalBytesSize-inputSize, wcfg.K, wcfg.L)
+	end := weibullCDF(ws.totalBytesSize, wcfg.K, wcfg.L)
+	// the CDF rounds to 1 far beyond the scale and the division below gives NaN, the node should have been split
+	if start >= 1 || end >= 1 {
+		ws.isBoundary = true
+		return nil
+	}
+	target := (end - start) / (1 - start)
+	p := float64(binary.BigEndian.Uint32(h)) / math.MaxUint32
+	if p < target {
+		ws.isBoundary = true
+	}
+
+	return nil
+}
+
+func (ws *WeibullSplitter) Reset() {
+	ws.totalBytesSize = 0
+	ws.totalPairsNumber = 0
+	ws.isBoundary = false
+}
+
+// weibullCDF returns the CDF of weibull distribution with shape K and scale L
+func weibullCDF(x int, K, L float64) float64 {
+	return 1 - math.Exp(-math.Pow(float64(x)/L, K))
+}
+
+var _ Splitter = &RollingHashSplitter{}
+
+// RollingHashSplitter splits nodes by the buzhash of a window over the serialized pairs, so boundaries depend on the
+// local content rather than the whole pairs. The pattern to match gets easier as the node grows, which reduces very
+// large nodes.
+type RollingHashSplitter struct {
+	isBoundary       bool
+	totalBytesSize   int
+	totalPairsNumber int
+	config           *TreeConfig
+
+	window []byte
+	// position of the oldest byte in window
+	pos  int
+	hash uint32
+	// hash of the window with zero bytes, the window starts with zero bytes after reset
+	zeroHash uint32
+}
+
+func NewRollingHashSplitter(config *TreeConfig) *RollingHashSplitter {
+	if config.Strategy.RollingHash.RollingHashWindow == 0 {
+		panic(fmt.Errorf("invalid rolling hash window: 0"))
+	}
+	rs := &RollingHashSplitter{
+		config: config,
+		window: make([]byte, config.Strategy.RollingHash.RollingHashWindow),
+	}
+	for range rs.window {
+		rs.zeroHash = bits.RotateLeft32(rs.zeroHash, 1) ^ buzhashTable[0]
+	}
+	rs.hash = rs.zeroHash
+	return rs
+}
+
+func (rs *RollingHashSplitter) IsBoundary() bool {
+	return rs.isBoundary
+}
+
+func (rs *RollingHashSplitter) Append(key, val []byte) error {
+	// can't append until reset splitter after boundary generated
+	if rs.isBoundary {
+		return fmt.Errorf("boundary generated but not reset")
+	}
+	rs.totalPairsNumber += 1
+
+	matched := false
+	for _, data := range [][]byte{key, val} {
+		for _, b := range data {
+			rs.roll(b)
+			rs.totalBytesSize++
+			if rs.totalBytesSize >= rs.config.MinNodeSize && rs.hash&rs.pattern() == 0 {
+				matched = true
+			}
+		}
+	}
+
+	// the boundary can only be at the end of a pair
+	if matched || rs.totalPairsNumber >= rs.config.MaxPairsInNode {
+		rs.isBoundary = true
+	}
+	return nil
+}
+
+// roll moves the window forward by the byte
+func (rs *RollingHashSplitter) roll(b byte) {
+	out := rs.window[rs.pos]
+	rs.window[rs.pos] = b
+	rs.pos = (rs.pos + 1) % len(rs.window)
+	rs.hash = bits.RotateLeft32(rs.hash, 1) ^ bits.RotateLeft32(buzhashTable[out], len(rs.window)) ^ buzhashTable[b]
+}
+
+// pattern returns the mask to match, it has one bit less for every 1<<ChunkingFactor bytes in the node
+func (rs *RollingHashSplitter) pattern() uint32 {
+	factor := rs.config.Strategy.RollingHash.ChunkingFactor
+	shift := factor - rs.totalBytesSize>>factor
+	if shift <= 0 {
+		return 0
+	}
+	return uint32(1<<shift - 1)
+}
+
+func (rs *RollingHashSplitter) Reset() {
+	rs.totalBytesSize = 0
+	rs.totalPairsNumber = 0
+	rs.isBoundary = false
+	for i := range rs.window {
+		rs.window[i] = 0
+	}
+	rs.pos = 0
+	rs.hash = rs.zeroHash
+}
+
+// buzhashTable maps bytes to random values, it's generated by splitmix64 with a fixed seed and must never change, or
+// the boundaries of existing trees change
+var buzhashTable = func() [256]uint32 {
+	var table [256]uint32
+	state := uint64(0x70726f6c6c79)
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		z ^= z >> 31
+		table[i] = uint32(z >> 32)
+	}
+	return table
+}()
@@ -0,0 +1,282 @@
+package tree
+
+import (
+	"bytes"
+	"context"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/multiformats/go-multicodec"
+	"github.com/zeebo/assert"
+	"testing"
+)
+
+// buildTreeWithConfig builds the tree, reloads it from the tree cid and checks all pairs
+func buildTreeWithConfig(t *testing.T, cfg *TreeConfig, keys [][]byte, vals []ipld.Node) *ProllyTree {
+	ctx := context.Background()
+	ns := TestMemNodeStore()
+	framework, err := NewFramework(ctx, ns, cfg, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, framework.AppendBatch(ctx, keys, vals))
+	_, treeCid, err := framework.BuildTree(ctx)
+	assert.NoError(t, err)
+
+	tree, err := LoadProllyTreeFromRootCid(treeCid, ns)
+	assert.NoError(t, err)
+	loadedCfg := tree.TreeConfig()
+	assert.True(t, loadedCfg.Equal(cfg))
+	assert.Equal(t, int(tree.TreeCount()), len(keys))
+	for i := range keys {
+		val, err := tree.Get(keys[i])
+		assert.NoError(t, err)
+		assert.Equal(t, val, vals[i])
+	}
+	return tree
+}
+
+// walkLeaves calls fn with every leaf node of the tree and its cid in order
+func walkLeaves(t *testing.T, tree *ProllyTree, fn func(c cid.Cid, n *ProllyNode)) {
+	var walk func(c cid.Cid, n *ProllyNode)
+	walk = func(c cid.Cid, n *ProllyNode) {
+		if n.IsLeaf {
+			fn(c, n)
+			return
+		}
+		for i := 0; i < n.ItemCount(); i++ {
+			child, err := tree.ns.ReadNode(context.Background(), n.GetIdxLink(i))
+			assert.NoError(t, err)
+			walk(n.GetIdxLink(i), child)
+		}
+	}
+	walk(tree.Root, &tree.root)
+}
+
+// sharedLeaves returns the number of leaf nodes in b which are also in a
+func sharedLeaves(t *testing.T, a *ProllyTree, b *ProllyTree) (int, int) {
+	leaves := make(map[cid.Cid]bool)
+	walkLeaves(t, a, func(c cid.Cid, n *ProllyNode) {
+		leaves[c] = true
+	})
+	shared, total := 0, 0
+	walkLeaves(t, b, func(c cid.Cid, n *ProllyNode) {
+		if leaves[c] {
+			shared++
+		}
+		total++
+	})
+	return shared, total
+}
+
+func TestWeibullSplitter(t *testing.T) {
+	testKeys, testVals := RandomTestData(10000)
+	cfg := DefaultChunkConfig()
+	cfg.StrategyType = WeibullThreshold
+	cfg.Strategy = strategy{Weibull: &WeibullThresholdConfig{
+		K:            4,
+		L:            2048,
+		HashFunction: uint64(multicodec.Sha2_256),
+	}}
+	tree := buildTreeWithConfig(t, cfg, testKeys, testVals)
+
+	// pairs are about 60 bytes, most nodes should be near the scale
+	near, total := 0, 0
+	walkLeaves(t, tree, func(c cid.Cid, n *ProllyNode) {
+		if n.ItemCount() >= 10 && n.ItemCount() <= 50 {
+			near++
+		}
+		total++
+	})
+	assert.True(t, near*10 >= total*9)
+
+	another := *cfg
+	another.Strategy = strategy{Weibull: &WeibullThresholdConfig{K: 4, L: 1024}}
+	assert.False(t, another.Equal(cfg))
+
+	// nodes growing far beyond the scale are split as soon as the min size allows, not at MaxPairsInNode
+	past := *cfg
+	past.MinNodeSize = 1 << 13
+	past.MaxNodeSize = 1 << 20
+	past.Strategy = strategy{Weibull: &WeibullThresholdConfig{
+		K:            4,
+		L:            256,
+		HashFunction: uint64(multicodec.Sha2_256),
+	}}
+	tree = buildTreeWithConfig(t, &past, testKeys, testVals)
+	walkLeaves(t, tree, func(c cid.Cid, n *ProllyNode) {
+		assert.True(t, n.ItemCount() < 200)
+	})
+}
+
+func TestRollingHashSplitter(t *testing.T) {
+	// large and similar values
+	testKeys, _ := RandomTestData(3000)
+	testVals := make([]ipld.Node, len(testKeys))
+	for i := range testKeys {
+		val := bytes.Repeat([]byte("similar value "), 20)
+		testVals[i] = basicnode.NewBytes(append(val, testKeys[i]...))
+	}
+
+	cfg := DefaultChunkConfig()
+	cfg.MaxNodeSize = 1 << 15
+	cfg.StrategyType = RollingHash
+	cfg.Strategy = strategy{RollingHash: &RollingHashConfig{
+		RollingHashWindow: 64,
+		ChunkingFactor:    12,
+	}}
+	tree := buildTreeWithConfig(t, cfg, testKeys, testVals)
+
+	// changing a value only affects the nodes around it
+	vals := append([]ipld.Node{}, testVals...)
+	vals[1500] = basicnode.NewString("changed")
+	another := buildTreeWithConfig(t, cfg, testKeys, vals)
+	shared, total := sharedLeaves(t, tree, another)
+	assert.True(t, total > 50)
+	assert.True(t, shared >= total-3)
+
+	anotherCfg := *cfg
+	anotherCfg.Strategy = strategy{RollingHash: &RollingHashConfig{RollingHashWindow: 32, ChunkingFactor: 12}}
+	assert.False(t, anotherCfg.Equal(cfg))
+}
+
+func TestKeyOnlyChunking(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(10000)
+	keyOnly := true
+	cfg := DefaultChunkConfig()
+	cfg.KeyOnlyChunking = &keyOnly
+	// only keys are counted by the splitter, leave room for values so nodes are not split by MaxNodeSize
+	cfg.MaxNodeSize = 1 << 14
+	cfg.Strategy.Suffix.ChunkingFactor = 5
+	tree := buildTreeWithConfig(t, cfg, testKeys, testVals)
+	oldTree := buildTreeWithConfig(t, cfg, testKeys, testVals)
+
+	// a much larger value doesn't move boundaries, only the path to it is rewritten
+	vals := append([]ipld.Node{}, testVals...)
+	vals[5000] = basicnode.NewBytes(bytes.Repeat([]byte("changed"), 50))
+	assert.NoError(t, tree.Mutate())
+	assert.NoError(t, tree.Put(ctx, testKeys[5000], vals[5000]))
+	_, err := tree.Rebuild(ctx)
+	assert.NoError(t, err)
+	shared, total := sharedLeaves(t, oldTree, tree)
+	assert.True(t, total > 50)
+	assert.Equal(t, shared, total-1)
+
+	// the same as the tree built from the changed pairs
+	another := buildTreeWithConfig(t, cfg, testKeys, vals)
+	assert.Equal(t, tree.Root, another.Root)
+
+	legacy := *cfg
+	legacy.KeyOnlyChunking = nil
+	assert.False(t, legacy.Equal(cfg))
+
+	// branch keys are leaf boundaries, the fan-out of branch nodes must still be decided by the chunking factor rather
+	// than MinNodeSize
+	manyKeys, manyVals := RandomTestData(50000)
+	large := buildTreeWithConfig(t, cfg, manyKeys, manyVals)
+	var fanOuts []int
+	var walk func(n *ProllyNode)
+	walk = func(n *ProllyNode) {
+		for i := 0; !n.IsLeaf && i < n.ItemCount(); i++ {
+			child, err := large.ns.ReadNode(ctx, n.GetIdxLink(i))
+			assert.NoError(t, err)
+			if child.IsLeaf {
+				fanOuts = append(fanOuts, n.ItemCount())
+				return
+			}
+			walk(child)
+		}
+	}
+	walk(&large.root)
+	assert.True(t, len(fanOuts) > 10)
+	total, maxFanOut := 0, 0
+	for _, fanOut := range fanOuts[:len(fanOuts)-1] {
+		total += fanOut
+		if fanOut > maxFanOut {
+			maxFanOut = fanOut
+		}
+	}
+	assert.True(t, total/(len(fanOuts)-1) > 30)
+	assert.True(t, maxFanOut > 60)
+}
+
+func TestBranchConfig(t *testing.T) {
+	testKeys, testVals := RandomTestData(20000)
+	cfg := DefaultChunkConfig()
+	tree := buildTreeWithConfig(t, cfg, testKeys, testVals)
+	height, err := tree.height()
+	assert.NoError(t, err)
+
+	// larger branch nodes with the same leaf nodes
+	branchCfg := *cfg
+	branchCfg.Branch = &BranchConfig{
+		MinNodeSize:    1 << 15,
+		MaxNodeSize:    1 << 16,
+		MaxPairsInNode: 4000,
+		Strategy: &strategy{Suffix: &HashThresholdConfig{
+			ChunkingFactor: 8,
+			HashFunction:   uint64(multicodec.Sha2_256),
+		}},
+	}
+	another := buildTreeWithConfig(t, &branchCfg, testKeys, testVals)
+	anotherHeight, err := another.height()
+	assert.NoError(t, err)
+	assert.True(t, anotherHeight < height)
+	shared, total := sharedLeaves(t, tree, another)
+	assert.Equal(t, shared, total)
+	assert.False(t, branchCfg.Equal(cfg))
+
+	// branch nodes are rebuilt with the branch config too
+	ctx := context.Background()
+	assert.NoError(t, another.Mutate())
+	assert.NoError(t, another.Put(ctx, []byte("new key"), basicnode.NewString("new value")))
+	_, err = another.Rebuild(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int(another.TreeCount()), len(testKeys)+1)
+	anotherHeight, err = another.height()
+	assert.NoError(t, err)
+	assert.True(t, anotherHeight < height)
+
+	// the strategy of leaf nodes is used if it's absent
+	branchCfg.Branch.Strategy = nil
+	another = buildTreeWithConfig(t, &branchCfg, testKeys, testVals)
+	shared, total = sharedLeaves(t, tree, another)
+	assert.Equal(t, shared, total)
+
+	branchCfg.Branch.Strategy = &strategy{Weibull: &WeibullThresholdConfig{K: 4, L: 1 << 14}}
+	_, err = NewFramework(ctx, TestMemNodeStore(), &branchCfg, nil)
+	assert.Error(t, err)
+}
+
+func TestRollingHashResync(t *testing.T) {
+	content := make([]byte, 2000)
+	testRand.Read(content)
+	for _, window := range []uint32{48, 64, 100} {
+		cfg := DefaultChunkConfig()
+		cfg.StrategyType = RollingHash
+		cfg.Strategy = strategy{RollingHash: &RollingHashConfig{RollingHashWindow: window, ChunkingFactor: 12}}
+		rs := NewRollingHashSplitter(cfg)
+
+		// the positions in the content where the hash matches, they only depend on the last window bytes
+		var expected []int
+		for offset := 0; offset < 40; offset++ {
+			rs.Reset()
+			prefix := make([]byte, offset)
+			testRand.Read(prefix)
+			for _, b := range prefix {
+				rs.roll(b)
+			}
+			var matches []int
+			for i, b := range content {
+				rs.roll(b)
+				if i >= int(window) && rs.hash&0xff == 0 {
+					matches = append(matches, i)
+				}
+			}
+			assert.True(t, len(matches) > 0)
+			if expected == nil {
+				expected = matches
+			}
+			assert.DeepEqual(t, matches, expected)
+		}
+	}
+}
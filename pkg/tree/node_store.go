@@ -18,6 +18,7 @@ type StoreConfig struct {
 }
 
 var _ NodeStore = &BlockNodeStore{}
+var _ MultiProofStore = &BlockNodeStore{}
 
 type BlockNodeStore struct {
 	bs    blockstore.Blockstore
@@ -74,6 +75,52 @@ func (ns *BlockNodeStore) ReadProof(ctx context.Context, c cid.Cid) (Proof, erro
 	return *prf, nil
 }
 
+func (ns *BlockNodeStore) WriteMultiProof(ctx context.Context, prf *MultiProof, prefix *cid.Prefix) (cid.Cid, error) {
+	var linkProto cidlink.LinkPrototype
+	if prefix == nil {
+		// default linkproto
+		linkProto = DefaultLinkProto
+	} else {
+		linkProto = cidlink.LinkPrototype{Prefix: *prefix}
+	}
+	ipldNode, err := prf.ToNode()
+	if err != nil {
+		return cid.Undef, err
+	}
+	lnk, err := ns.lsys.Store(ipld.LinkContext{Ctx: ctx}, linkProto, ipldNode)
+	if err != nil {
+		return cid.Undef, err
+	}
+	c := lnk.(cidlink.Link).Cid
+
+	// the caller may still change the proof, and the cached proof is copied again when read
+	cached := prf.clone()
+	go func() {
+		if ns.cache != nil {
+			ns.cache.Add(c, cached)
+		}
+	}()
+
+	return c, nil
+}
+
+func (ns *BlockNodeStore) ReadMultiProof(ctx context.Context, c cid.Cid) (*MultiProof, error) {
+	var inCache bool
+	if ns.cache != nil {
+		var res interface{}
+		res, inCache = ns.cache.Get(c)
+		if inCache {
+			return res.(*MultiProof).clone(), nil
+		}
+	}
+	nd, err := ns.lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, MultiProofPrototype.Representation())
+	if err != nil {
+		return nil, err
+	}
+
+	return UnwrapMultiProof(nd)
+}
+
 func (ns *BlockNodeStore) LinkSystem() *ipld.LinkSystem {
 	return ns.lsys
 }
@@ -180,6 +227,7 @@ func (ns *BlockNodeStore) ReadTree(ctx context.Context, c cid.Cid) (*ProllyTree,
 		res, inCache = ns.cache.Get(c)
 		if inCache {
 			tree := res.(ProllyTree)
+			tree.treeCid = &c
 			return &tree, nil
 		}
 	}
@@ -244,6 +292,7 @@ func TestMemNodeStore() NodeStore {
 }
 
 var _ NodeStore = &LinkSystemNodeStore{}
+var _ MultiProofStore = &LinkSystemNodeStore{}
 
 type LinkSystemNodeStore struct {
 	lsys *linking.LinkSystem
@@ -284,6 +333,36 @@ func (ns *LinkSystemNodeStore) ReadProof(ctx context.Context, c cid.Cid) (Proof,
 	return *prf, nil
 }
 
+func (ns *LinkSystemNodeStore) WriteMultiProof(ctx context.Context, prf *MultiProof, prefix *cid.Prefix) (cid.Cid, error) {
+	var linkProto cidlink.LinkPrototype
+	if prefix == nil {
+		// default linkproto
+		linkProto = DefaultLinkProto
+	} else {
+		linkProto = cidlink.LinkPrototype{Prefix: *prefix}
+	}
+	ipldNode, err := prf.ToNode()
+	if err != nil {
+		return cid.Undef, err
+	}
+	lnk, err := ns.lsys.Store(ipld.LinkContext{Ctx: ctx}, linkProto, ipldNode)
+	if err != nil {
+		return cid.Undef, err
+	}
+	c := lnk.(cidlink.Link).Cid
+
+	return c, nil
+}
+
+func (ns *LinkSystemNodeStore) ReadMultiProof(ctx context.Context, c cid.Cid) (*MultiProof, error) {
+	nd, err := ns.lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, MultiProofPrototype.Representation())
+	if err != nil {
+		return nil, err
+	}
+
+	return UnwrapMultiProof(nd)
+}
+
 func (ns *LinkSystemNodeStore) LinkSystem() *ipld.LinkSystem {
 	return ns.lsys
 }
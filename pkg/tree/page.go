@@ -0,0 +1,105 @@
+package tree
+
+import (
+	"context"
+	"fmt"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+)
+
+// PageToken marks the end of a page, it is bound to the version(cid) of the tree which generated it
+type PageToken struct {
+	Tree    cid.Cid
+	LastKey []byte
+}
+
+// Page is the result of SearchPage
+type Page struct {
+	Keys   [][]byte
+	Values []ipld.Node
+	// Next is nil if there are no more pairs in the range
+	Next *PageToken
+}
+
+// SearchPage returns at most limit pairs in [start, end] after skipping the first offset pairs in the range, nil
+// start or end means the range is unbounded on that side. The skipped pairs are never read, whole subtrees are
+// skipped by their SubtreeCount.
+func (pt *ProllyTree) SearchPage(ctx context.Context, start []byte, end []byte, offset uint32, limit uint32) (*Page, error) {
+	// the page token is bound to the tree cid
+	if pt.mutating || pt.treeCid == nil {
+		return nil, fmt.Errorf("Cannot search page while tree is being mutated. Apply changes with Rebuild first.")
+	}
+	var startRank uint32
+	if start != nil {
+		var err error
+		startRank, err = pt.Rank(start)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// a large offset must not wrap around to the pairs before start
+	index := uint64(startRank) + uint64(offset)
+	if index >= uint64(pt.TreeCount()) {
+		return &Page{}, nil
+	}
+	return pt.searchPageFromIndex(ctx, uint32(index), end, limit)
+}
+
+// SearchPageAfter returns the page following the token, the token must be generated by the same version of the tree
+func (pt *ProllyTree) SearchPageAfter(ctx context.Context, token *PageToken, end []byte, limit uint32) (*Page, error) {
+	if pt.mutating {
+		return nil, fmt.Errorf("Cannot search page while tree is being mutated. Apply changes with Rebuild first.")
+	}
+	if token == nil {
+		return nil, fmt.Errorf("nil page token")
+	}
+	if pt.treeCid == nil || !token.Tree.Equals(*pt.treeCid) {
+		return nil, fmt.Errorf("page token is generated by another version of the tree: %s", token.Tree)
+	}
+	rank, err := pt.Rank(token.LastKey)
+	if err != nil {
+		return nil, err
+	}
+	// the last key is in the same version of tree, start from the next one
+	return pt.searchPageFromIndex(ctx, rank+1, end, limit)
+}
+
+func (pt *ProllyTree) searchPageFromIndex(ctx context.Context, index uint32, end []byte, limit uint32) (*Page, error) {
+	page := &Page{}
+	if index >= pt.TreeCount() || limit == 0 {
+		return page, nil
+	}
+
+	cur, err := CursorAtIndex(&pt.root, index, pt.ns)
+	if err != nil {
+		return nil, err
+	}
+	for uint32(len(page.Keys)) < limit && cur.IsValid() {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		key := cur.GetKey()
+		if end != nil && pt.compareFunc(key, end) > 0 {
+			return page, nil
+		}
+		page.Keys = append(page.Keys, key)
+		page.Values = append(page.Values, cur.GetValue())
+
+		err = cur.Advance()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// more pairs in range
+	if cur.IsValid() && (end == nil || pt.compareFunc(cur.GetKey(), end) <= 0) {
+		page.Next = &PageToken{
+			Tree:    *pt.treeCid,
+			LastKey: page.Keys[len(page.Keys)-1],
+		}
+	}
+
+	return page, nil
+}
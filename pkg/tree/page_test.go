@@ -0,0 +1,68 @@
+package tree
+
+import (
+	"context"
+	"github.com/zeebo/assert"
+	"math"
+	"testing"
+)
+
+func TestSearchPage(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(10000)
+	tree, _ := BuildTestTreeFromData(t, testKeys, testVals)
+
+	page, err := tree.SearchPage(ctx, testKeys[100], testKeys[8000], 5000, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, len(page.Keys), 100)
+	for i := range page.Keys {
+		assert.Equal(t, page.Keys[i], testKeys[5100+i])
+		assert.Equal(t, page.Values[i], testVals[5100+i])
+	}
+	assert.NotNil(t, page.Next)
+	assert.Equal(t, page.Next.LastKey, testKeys[5199])
+
+	// walk the rest of the range with tokens
+	num := 5200
+	for page.Next != nil {
+		page, err = tree.SearchPageAfter(ctx, page.Next, testKeys[8000], 333)
+		assert.NoError(t, err)
+		for i := range page.Keys {
+			assert.Equal(t, page.Keys[i], testKeys[num])
+			num++
+		}
+	}
+	assert.Equal(t, num, 8001)
+
+	// unbounded range
+	page, err = tree.SearchPage(ctx, nil, nil, 9990, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, len(page.Keys), 10)
+	assert.Nil(t, page.Next)
+
+	// offset beyond the range
+	page, err = tree.SearchPage(ctx, testKeys[0], testKeys[10], 20, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, len(page.Keys), 0)
+	page, err = tree.SearchPage(ctx, testKeys[100], nil, math.MaxUint32, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, len(page.Keys), 0)
+	assert.Nil(t, page.Next)
+
+	// the tree without cid can't generate page tokens
+	treeCid := tree.treeCid
+	tree.treeCid = nil
+	_, err = tree.SearchPage(ctx, nil, nil, 0, 10)
+	assert.Error(t, err)
+	tree.treeCid = treeCid
+
+	// token of another tree version
+	page, err = tree.SearchPage(ctx, nil, nil, 0, 10)
+	assert.NoError(t, err)
+	assert.NoError(t, tree.Mutate())
+	assert.NoError(t, tree.Delete(ctx, testKeys[3]))
+	_, err = tree.Rebuild(ctx)
+	assert.NoError(t, err)
+	_, err = tree.SearchPageAfter(ctx, page.Next, nil, 10)
+	assert.Error(t, err)
+}
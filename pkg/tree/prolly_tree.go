@@ -1,7 +1,6 @@
 package tree
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -20,6 +19,8 @@ type ProllyTree struct {
 	ns         NodeStore
 	treeConfig TreeConfig
 	treeCid    *cid.Cid
+	// key order of the tree, resolved from treeConfig
+	compareFunc CompareFunc
 
 	mutating  bool
 	mutations *Mutations
@@ -50,6 +51,10 @@ func (pt *ProllyTree) LoadProllyTreeFromRootNode(ns NodeStore) error {
 		return err
 	}
 	pt.treeConfig = *config
+	pt.compareFunc, err = config.CompareFunc()
+	if err != nil {
+		return err
+	}
 
 	pt.ns = ns
 	return nil
@@ -75,37 +80,112 @@ func (pt *ProllyTree) Get(key []byte) (ipld.Node, error) {
 		}
 	}
 
-	cur, err := CursorAtItem(&pt.root, key, DefaultCompareFunc, pt.ns)
+	cur, err := CursorAtItem(&pt.root, key, pt.compareFunc, pt.ns)
 	if err != nil {
 		return nil, err
 	}
-	if !cur.IsValid() || DefaultCompareFunc(cur.GetKey(), key) != 0 {
+	if !cur.IsValid() || pt.compareFunc(cur.GetKey(), key) != 0 {
 		return nil, KeyNotFound
 	}
 
 	return cur.GetValue(), nil
 }
 
+// GetByIndex returns the index-th(from 0) pair in the tree in O(depth) with SubtreeCount
+func (pt *ProllyTree) GetByIndex(index uint32) ([]byte, ipld.Node, error) {
+	if pt.mutating {
+		return nil, nil, fmt.Errorf("Cannot get pair by index while tree is being mutated. Apply changes with Rebuild first.")
+	}
+	cur, err := CursorAtIndex(&pt.root, index, pt.ns)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cur.GetKey(), cur.GetValue(), nil
+}
+
+// Rank returns the number of keys smaller than the key in the tree, the key itself may be absent
+func (pt *ProllyTree) Rank(key []byte) (uint32, error) {
+	if pt.mutating {
+		return 0, fmt.Errorf("Cannot get rank while tree is being mutated. Apply changes with Rebuild first.")
+	}
+	if pt.root.IsEmpty() {
+		return 0, nil
+	}
+	cur, err := CursorAtItem(&pt.root, key, pt.compareFunc, pt.ns)
+	if err != nil {
+		return 0, err
+	}
+	rank := cur.rank()
+	// the key is bigger than all keys in the tree
+	if pt.compareFunc(cur.GetKey(), key) < 0 {
+		rank++
+	}
+	return rank, nil
+}
+
+// CountRange returns the number of keys in [start, end] in O(depth) by subtracting the ranks of the bounds, nil start or
+// end means the range is unbounded on that side
+func (pt *ProllyTree) CountRange(start []byte, end []byte) (uint32, error) {
+	if pt.mutating {
+		return 0, fmt.Errorf("Cannot count range while tree is being mutated. Apply changes with Rebuild first.")
+	}
+	if pt.root.IsEmpty() {
+		return 0, nil
+	}
+
+	var lo uint32
+	var err error
+	if start != nil {
+		lo, err = pt.Rank(start)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	hi := pt.TreeCount()
+	if end != nil {
+		cur, err := CursorAtItem(&pt.root, end, pt.compareFunc, pt.ns)
+		if err != nil {
+			return 0, err
+		}
+		// number of keys not bigger than end
+		hi = cur.rank()
+		if pt.compareFunc(cur.GetKey(), end) <= 0 {
+			hi++
+		}
+	}
+
+	if hi <= lo {
+		return 0, nil
+	}
+	return hi - lo, nil
+}
+
 func (pt *ProllyTree) GetProof(key []byte) (Proof, error) {
 	if pt.mutating {
 		return nil, fmt.Errorf("Cannot get proof while tree is being mutated. Apply changes with Rebuild first.")
 	}
 
-	cur, err := CursorAtItem(&pt.root, key, DefaultCompareFunc, pt.ns)
+	cur, err := CursorAtItem(&pt.root, key, pt.compareFunc, pt.ns)
 	if err != nil {
 		return nil, err
 	}
-	if !cur.IsValid() || DefaultCompareFunc(cur.GetKey(), key) != 0 {
+	if !cur.IsValid() || pt.compareFunc(cur.GetKey(), key) != 0 {
 		return nil, KeyNotFound
 	}
 
-	proof := Proof{}
-
 	// Only prove leaves
 	if !cur.node.IsLeaf {
 		return nil, KeyNotFound
 	}
 
+	return pt.proofFromCursor(cur), nil
+}
+
+// proofFromCursor generates the proof of the pair the leaf cursor points at
+func (pt *ProllyTree) proofFromCursor(cur *Cursor) Proof {
+	proof := Proof{}
+
 	for cur.parent != nil {
 		index := cur.GetIndex()
 		link := cur.parent.GetLink()
@@ -133,13 +213,44 @@ func (pt *ProllyTree) GetProof(key []byte) (Proof, error) {
 		Index: 2,
 	})
 
-	return proof, nil
+	return proof
+}
+
+// SearchOption configures the range of Search, SearchReverse and NewCursorIterator
+type SearchOption func(opts *searchOptions)
+
+type searchOptions struct {
+	exclusiveEnd bool
 }
 
-func (pt *ProllyTree) Search(ctx context.Context, start []byte, end []byte) (*Iterator, error) {
+// WithExclusiveEnd makes the search range half-open [start, end), the end key itself is not included
+func WithExclusiveEnd() SearchOption {
+	return func(opts *searchOptions) {
+		opts.exclusiveEnd = true
+	}
+}
+
+func newSearchOptions(opts []SearchOption) *searchOptions {
+	options := &searchOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// Search returns the pairs in [start, end] prefetched by a producer goroutine, use NewCursorIterator to iterate on the
+// caller's goroutine without prefetching
+func (pt *ProllyTree) Search(ctx context.Context, start []byte, end []byte, opts ...SearchOption) (*Iterator, error) {
 	if start == nil && end == nil {
 		return nil, fmt.Errorf("empty start and end key")
 	}
+	options := newSearchOptions(opts)
+	iter := NewIterator(-1)
+	if pt.root.IsEmpty() {
+		iter.finish(nil)
+		return iter, nil
+	}
+
 	var err error
 	if start == nil {
 		start, err = pt.FirstKey()
@@ -152,48 +263,135 @@ func (pt *ProllyTree) Search(ctx context.Context, start []byte, end []byte) (*It
 		if err != nil {
 			return nil, err
 		}
+		// the last key is always in range
+		options.exclusiveEnd = false
 	}
 
-	cur, err := CursorAtItem(&pt.root, start, DefaultCompareFunc, pt.ns)
+	cur, err := CursorAtItem(&pt.root, start, pt.compareFunc, pt.ns)
 	if err != nil {
 		return nil, err
 	}
-	iter := NewIterator(-1)
-	go func() {
-		defer iter.finish()
-		for {
-			select {
-			case <-ctx.Done():
-				// todo: return error info
-				return
-			default:
-			}
-			if !cur.IsValid() {
-				break
-			}
-			key := cur.GetKey()
+	go produceRange(ctx, iter, cur, start, end, pt.compareFunc, options, false)
 
-			if DefaultCompareFunc(key, start) < 0 || DefaultCompareFunc(key, end) > 0 {
-				break
-			}
+	return iter, nil
+}
 
-			val := cur.GetValue()
+// produceRange sends the pairs in range from the cursor to the iterator, moving the cursor backwards if reverse is
+// true. It stops if the cursor leaves the range, an error happens or the iterator is closed.
+func produceRange(ctx context.Context, iter *Iterator, cur *Cursor, start []byte, end []byte, cp CompareFunc,
+	options *searchOptions, reverse bool) {
+	var err error
+	defer func() {
+		iter.finish(err)
+	}()
+	for cur.IsValid() {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		key := cur.GetKey()
+		if cp(key, start) < 0 || !options.beforeEnd(key, end, cp) {
+			return
+		}
 
-			iter.receivePair(key, val)
+		err = iter.receivePair(ctx, key, cur.GetValue())
+		if err != nil {
+			return
+		}
 
+		if reverse {
+			err = cur.Retreat()
+		} else {
 			err = cur.Advance()
-			if err != nil {
-				// todo: return error info
-				return
-			}
 		}
-	}()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// beforeEnd returns whether the key is not beyond the end of the range
+func (opts *searchOptions) beforeEnd(key []byte, end []byte, cp CompareFunc) bool {
+	cmp := cp(key, end)
+	if opts.exclusiveEnd {
+		return cmp < 0
+	}
+	return cmp <= 0
+}
+
+// SearchReverse returns the pairs in [start, end] in descending order of keys
+func (pt *ProllyTree) SearchReverse(ctx context.Context, start []byte, end []byte, opts ...SearchOption) (*Iterator, error) {
+	if start == nil && end == nil {
+		return nil, fmt.Errorf("empty start and end key")
+	}
+	options := newSearchOptions(opts)
+	iter := NewIterator(-1)
+	if pt.root.IsEmpty() {
+		iter.finish(nil)
+		return iter, nil
+	}
+
+	var err error
+	if start == nil {
+		start, err = pt.FirstKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if end == nil {
+		end, err = pt.LastKey()
+		if err != nil {
+			return nil, err
+		}
+		// the last key is always in range
+		options.exclusiveEnd = false
+	}
+
+	cur, err := CursorAtItem(&pt.root, end, pt.compareFunc, pt.ns)
+	if err != nil {
+		return nil, err
+	}
+	// the cursor is at the first key not smaller than end, retreat if it's out of the range
+	if cur.IsValid() && !options.beforeEnd(cur.GetKey(), end, pt.compareFunc) {
+		err = cur.Retreat()
+		if err != nil {
+			return nil, err
+		}
+	}
+	go produceRange(ctx, iter, cur, start, end, pt.compareFunc, options, true)
 
 	return iter, nil
 }
 
+// ScanPrefix returns all pairs whose keys have the prefix, it's only supported in trees ordered by BytesCompare
+func (pt *ProllyTree) ScanPrefix(ctx context.Context, prefix []byte) (*Iterator, error) {
+	if pt.treeConfig.compareIndicator() != BytesCompare {
+		return nil, fmt.Errorf("prefix scan is not supported in trees with custom key order")
+	}
+	start := make([]byte, len(prefix))
+	copy(start, prefix)
+	end := PrefixEnd(prefix)
+	if end == nil {
+		return pt.Search(ctx, start, nil)
+	}
+	return pt.Search(ctx, start, end, WithExclusiveEnd())
+}
+
+// PrefixEnd returns the smallest key bigger than all keys with the prefix, it is the exclusive upper bound for the
+// prefix. It returns nil if there is no such key(the prefix is empty or only consists of 0xff).
+func PrefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			end := make([]byte, i+1)
+			copy(end, prefix[:i+1])
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
+
 func (pt *ProllyTree) Mutate() error {
-	pt.mutations = NewMutations()
+	pt.mutations = NewMutationsWithCompareFunc(pt.compareFunc)
 	pt.mutating = true
 	return nil
 }
@@ -206,7 +404,7 @@ func (pt *ProllyTree) Put(ctx context.Context, key []byte, val ipld.Node) error
 	if !pt.mutating {
 		return fmt.Errorf("please call ProllyTree.Mutate firstly")
 	}
-	cur, err := CursorAtItem(&pt.root, key, DefaultCompareFunc, pt.ns)
+	cur, err := CursorAtItem(&pt.root, key, pt.compareFunc, pt.ns)
 	if err != nil {
 		return err
 	}
@@ -226,7 +424,7 @@ func (pt *ProllyTree) Put(ctx context.Context, key []byte, val ipld.Node) error
 		mut.Op = Add
 	} else {
 		// Modify
-		if DefaultCompareFunc(cur.GetKey(), key) == 0 {
+		if pt.compareFunc(cur.GetKey(), key) == 0 {
 			mut.Op = Modify
 		} else {
 			//Add new pair
@@ -246,14 +444,14 @@ func (pt *ProllyTree) Delete(ctx context.Context, key []byte) error {
 	if !pt.mutating {
 		return fmt.Errorf("please call ProllyTree.Mutate firstly")
 	}
-	cur, err := CursorAtItem(&pt.root, key, DefaultCompareFunc, pt.ns)
+	cur, err := CursorAtItem(&pt.root, key, pt.compareFunc, pt.ns)
 	if err != nil {
 		return err
 	}
 
 	if cur.IsValid() {
 		// delete
-		if DefaultCompareFunc(cur.GetKey(), key) == 0 {
+		if pt.compareFunc(cur.GetKey(), key) == 0 {
 			err = pt.mutations.AddMutation(&Mutation{
 				Key: key,
 				Op:  Remove,
@@ -273,10 +471,16 @@ func (pt *ProllyTree) Rebuild(ctx context.Context) (cid.Cid, error) {
 	pt.mutations.Finish()
 
 	mut, err := pt.mutations.NextMutation()
+	// nothing changed
+	if err == io.EOF && pt.treeCid != nil {
+		pt.mutating = false
+		pt.mutations = nil
+		return *pt.treeCid, nil
+	}
 	if err != nil {
 		return cid.Undef, err
 	}
-	cur, err := CursorAtItem(&pt.root, mut.Key, DefaultCompareFunc, pt.ns)
+	cur, err := CursorAtItem(&pt.root, mut.Key, pt.compareFunc, pt.ns)
 	if err != nil {
 		return cid.Undef, err
 	}
@@ -287,7 +491,7 @@ func (pt *ProllyTree) Rebuild(ctx context.Context) (cid.Cid, error) {
 	for {
 
 		if mut.Op == Add {
-			if !cur.node.IsEmpty() && cur.IsValid() && DefaultCompareFunc(cur.GetKey(), mut.Key) == 0 {
+			if !cur.node.IsEmpty() && cur.IsValid() && pt.compareFunc(cur.GetKey(), mut.Key) == 0 {
 				return cid.Undef, fmt.Errorf("can not add exist key in the tree")
 			}
 			err := framework.Append(ctx, mut.Key, mut.Val)
@@ -295,7 +499,7 @@ func (pt *ProllyTree) Rebuild(ctx context.Context) (cid.Cid, error) {
 				return cid.Undef, err
 			}
 		} else {
-			if DefaultCompareFunc(cur.GetKey(), mut.Key) != 0 {
+			if pt.compareFunc(cur.GetKey(), mut.Key) != 0 {
 				return cid.Undef, fmt.Errorf("modified or remove key should be the same with origin key")
 			}
 			if mut.Op == Modify {
@@ -324,12 +528,12 @@ func (pt *ProllyTree) Rebuild(ctx context.Context) (cid.Cid, error) {
 			return cid.Undef, err
 		}
 
-		cur, err = CursorAtItem(&pt.root, mut.Key, DefaultCompareFunc, pt.ns)
+		cur, err = CursorAtItem(&pt.root, mut.Key, pt.compareFunc, pt.ns)
 		if err != nil {
 			return cid.Undef, err
 		}
 		// the key is bigger than all keys in the tree, advance it
-		if cur.IsBiggerThanTheNode(mut.Key) {
+		if cur.IsBiggerThanTheNode(mut.Key, pt.compareFunc) {
 			err = cur.Advance()
 			if err != nil {
 				return cid.Undef, err
@@ -351,6 +555,7 @@ func (pt *ProllyTree) Rebuild(ctx context.Context) (cid.Cid, error) {
 	pt.ProllyRoot = newTree.ProllyRoot
 	pt.root = newTree.root
 	pt.treeConfig = newTree.treeConfig
+	pt.compareFunc = newTree.compareFunc
 	pt.ns = newTree.ns
 	pt.treeCid = newTree.treeCid
 
@@ -360,117 +565,48 @@ func (pt *ProllyTree) Rebuild(ctx context.Context) (cid.Cid, error) {
 	return newTreeCid, nil
 }
 
-func (pt *ProllyTree) Diff(other *ProllyTree) (*Diffs, error) {
+// Diff returns the changes from the tree to the other tree in ascending order of keys, including added, modified and
+// removed keys. The changes are produced in another goroutine until the ctx is canceled or the Diffs is closed.
+func (pt *ProllyTree) Diff(ctx context.Context, other *ProllyTree) (*Diffs, error) {
 	diffs := NewDiffs()
 	otherConfig := other.TreeConfig()
 	config := pt.TreeConfig()
 	if !config.Equal(&otherConfig) {
 		return nil, fmt.Errorf("diff between trees with different config is not allowed")
 	}
-	if pt.Root.Equals(other.Root) {
-		return nil, nil
-	}
-	firstKeyBase, err := pt.FirstKey()
-	if err != nil {
-		return nil, err
-	}
-	curBase, err := CursorAtItem(&pt.root, firstKeyBase, DefaultCompareFunc, pt.ns)
-	if err != nil {
-		return nil, err
-	}
-
-	firstKeyOther, err := other.FirstKey()
-	if err != nil {
-		return nil, err
-	}
-	curOther, err := CursorAtItem(&other.root, firstKeyOther, DefaultCompareFunc, other.ns)
-	if err != nil {
-		return nil, err
-	}
 
 	go func() {
-		defer diffs.Close()
-		for {
-			if !curBase.IsValid() {
-				if !curOther.IsValid() {
-					return
-				} else {
-					for curOther.IsValid() {
-						err = diffs.AddMutation(&Mutation{
-							Key: curOther.GetKey(),
-							Val: curOther.GetValue(),
-							Op:  Add,
-						})
-						if err != nil {
-							panic(err)
-						}
-						err = curOther.Advance()
-						if err != nil {
-							panic(err)
-						}
-					}
-				}
-			}
-			// if new tree cursor arrived end firstly, return(ignore delete option)
-			if !curOther.IsValid() {
-				return
-			}
-
-			cmp := DefaultCompareFunc(curBase.GetKey(), curOther.GetKey())
-			// ignore keys in the base which are missing from the new tree
-			if cmp < 0 {
-				err = curBase.Advance()
-				if err != nil {
-					// todo: better error handling
-					panic(err)
-				}
-				continue
-			} else if cmp > 0 {
-				err = diffs.AddMutation(&Mutation{
-					Key: curOther.GetKey(),
-					Val: curOther.GetValue(),
-					Op:  Add,
-				})
-				if err != nil {
-					panic(err)
-				}
-			} else {
-				// if k/v pair equal, try skipping common parts
-				if bytes.Equal(EncodeNode(curBase.GetValue()), EncodeNode(curOther.GetValue())) {
-					err = curBase.SkipCommon(curOther)
-					if err != nil {
-						panic(err)
-					}
-				} else {
-					err = diffs.AddMutation(&Mutation{
-						Key: curOther.GetKey(),
-						Val: curOther.GetValue(),
-						Op:  Modify,
-					})
-					if err != nil {
-						panic(err)
-					}
-				}
-			}
-			err = curBase.Advance()
-			if err != nil {
-				panic(err)
+		var err error
+		defer func() {
+			diffs.finish(err)
+		}()
+		err = forEachChange(ctx, pt, other, func(key []byte, old ipld.Node, new ipld.Node) error {
+			entry := &DiffEntry{
+				Key: key,
+				Old: old,
+				New: new,
 			}
-			err = curOther.Advance()
-			if err != nil {
-				panic(err)
+			switch {
+			case old == nil:
+				entry.Op = Add
+			case new == nil:
+				entry.Op = Remove
+			default:
+				entry.Op = Modify
 			}
-		}
+			return diffs.AddEntry(ctx, entry)
+		})
 	}()
 
 	return diffs, nil
 }
 
 func (pt *ProllyTree) Merge(ctx context.Context, other *ProllyTree) error {
-	diffs, err := pt.Diff(other)
+	diffs, err := pt.Diff(ctx, other)
 	if err != nil {
 		return err
 	}
+	defer diffs.Close()
 	err = pt.Mutate()
 	if err != nil {
 		return err
@@ -488,6 +624,9 @@ func (pt *ProllyTree) Merge(ctx context.Context, other *ProllyTree) error {
 			if err != nil {
 				return err
 			}
+		} else if mut.Op == Remove {
+			// keys missing from the other tree are kept
+			continue
 		} else {
 			return fmt.Errorf("unsupported action now")
 		}
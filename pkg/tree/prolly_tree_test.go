@@ -7,7 +7,9 @@ import (
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	basicnode "github.com/ipld/go-ipld-prime/node/basic"
 	"github.com/zeebo/assert"
+	"io"
 	"math/rand"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -234,6 +236,40 @@ func TestMutateSearch(t *testing.T) {
 	}
 }
 
+func TestRebuildWithoutMutations(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(1000)
+	tree, treeCid := BuildTestTreeFromData(t, testKeys, testVals)
+
+	// the current tree is returned and the tree leaves the mutating state
+	assert.NoError(t, tree.Mutate())
+	c, err := tree.Rebuild(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, c, treeCid)
+	assert.False(t, tree.IsMutating())
+	_, err = tree.GetProof(testKeys[0])
+	assert.NoError(t, err)
+
+	// the same for a loaded tree
+	loaded, err := LoadProllyTreeFromRootCid(treeCid, tree.ns)
+	assert.NoError(t, err)
+	assert.NoError(t, loaded.Mutate())
+	c, err = loaded.Rebuild(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, c, treeCid)
+	assert.False(t, loaded.IsMutating())
+
+	// the tree can still be mutated afterwards
+	assert.NoError(t, tree.Mutate())
+	assert.NoError(t, tree.Put(ctx, testKeys[0], basicnode.NewString("changed")))
+	c, err = tree.Rebuild(ctx)
+	assert.NoError(t, err)
+	assert.NotEqual(t, c, treeCid)
+	val, err := tree.Get(testKeys[0])
+	assert.NoError(t, err)
+	assert.Equal(t, val, basicnode.NewString("changed"))
+}
+
 func TestMergeTree(t *testing.T) {
 	count := 20000
 	testKeys, testVals := RandomTestData(count)
@@ -479,3 +515,528 @@ func TestCriticalCondition(t *testing.T) {
 		t.Log([]byte(kv))
 	}
 }
+
+func TestPositionalAccess(t *testing.T) {
+	testKeys, testVals := RandomTestData(10000)
+	tree, _ := BuildTestTreeFromData(t, testKeys, testVals)
+
+	for i := 0; i < len(testKeys); i += 7 {
+		k, v, err := tree.GetByIndex(uint32(i))
+		assert.NoError(t, err)
+		assert.Equal(t, k, testKeys[i])
+		assert.Equal(t, v, testVals[i])
+
+		rank, err := tree.Rank(testKeys[i])
+		assert.NoError(t, err)
+		assert.Equal(t, rank, uint32(i))
+
+		// absent key right after testKeys[i]
+		rank, err = tree.Rank(append(append([]byte{}, testKeys[i]...), 0))
+		assert.NoError(t, err)
+		assert.Equal(t, rank, uint32(i+1))
+	}
+
+	k, _, err := tree.GetByIndex(9999)
+	assert.NoError(t, err)
+	assert.Equal(t, k, testKeys[9999])
+	_, _, err = tree.GetByIndex(10000)
+	assert.Error(t, err)
+
+	rank, err := tree.Rank([]byte{})
+	assert.NoError(t, err)
+	assert.Equal(t, rank, uint32(0))
+	rank, err = tree.Rank(append(append([]byte{}, testKeys[9999]...), 0))
+	assert.NoError(t, err)
+	assert.Equal(t, rank, uint32(10000))
+
+	cur, err := CursorAtIndex(&tree.root, 5000, tree.ns)
+	assert.NoError(t, err)
+	for i := 5000; i < 5100; i++ {
+		assert.Equal(t, cur.GetKey(), testKeys[i])
+		assert.NoError(t, cur.Advance())
+	}
+}
+
+func TestCountRange(t *testing.T) {
+	testKeys, testVals := RandomTestData(10000)
+	tree, _ := BuildTestTreeFromData(t, testKeys, testVals)
+
+	ranges := []struct {
+		start []byte
+		end   []byte
+		count uint32
+	}{
+		{testKeys[100], testKeys[3000], 2901},
+		{testKeys[100], testKeys[100], 1},
+		{append(append([]byte{}, testKeys[100]...), 0), testKeys[200], 100},
+		{testKeys[200], testKeys[100], 0},
+		{nil, testKeys[99], 100},
+		{testKeys[9000], nil, 1000},
+		{nil, nil, 10000},
+		{[]byte{}, append(append([]byte{}, testKeys[9999]...), 0), 10000},
+	}
+	for _, r := range ranges {
+		count, err := tree.CountRange(r.start, r.end)
+		assert.NoError(t, err)
+		assert.Equal(t, count, r.count)
+	}
+}
+
+func TestCustomCompareFunc(t *testing.T) {
+	ctx := context.Background()
+	const reverseCompare = 0x300000
+	RegisterCompareFunc(reverseCompare, func(left, right []byte) int {
+		return bytes.Compare(right, left)
+	})
+
+	testKeys, testVals := RandomTestData(10000)
+	// descending keys
+	for i, j := 0, len(testKeys)-1; i < j; i, j = i+1, j-1 {
+		testKeys[i], testKeys[j] = testKeys[j], testKeys[i]
+		testVals[i], testVals[j] = testVals[j], testVals[i]
+	}
+
+	ns := TestMemNodeStore()
+	cfg := DefaultChunkConfig()
+	indicator := uint64(reverseCompare)
+	cfg.CompareFunction = &indicator
+	assert.False(t, cfg.Equal(DefaultChunkConfig()))
+
+	framework, err := NewFramework(ctx, ns, cfg, nil)
+	assert.NoError(t, err)
+	err = framework.AppendBatch(ctx, testKeys, testVals)
+	assert.NoError(t, err)
+	_, treeCid, err := framework.BuildTree(ctx)
+	assert.NoError(t, err)
+
+	// the order is loaded from the persisted config
+	tree, err := LoadProllyTreeFromRootCid(treeCid, ns)
+	assert.NoError(t, err)
+	loadedCfg := tree.TreeConfig()
+	assert.True(t, loadedCfg.Equal(cfg))
+
+	for i := 0; i < len(testKeys); i += 11 {
+		val, err := tree.Get(testKeys[i])
+		assert.NoError(t, err)
+		assert.Equal(t, val, testVals[i])
+	}
+
+	iter, err := tree.Search(ctx, testKeys[100], testKeys[200])
+	assert.NoError(t, err)
+	for i := 100; i <= 200; i++ {
+		k, v, err := iter.NextPair()
+		assert.NoError(t, err)
+		assert.Equal(t, k, testKeys[i])
+		assert.Equal(t, v, testVals[i])
+	}
+	_, _, err = iter.NextPair()
+	assert.Equal(t, err, io.EOF)
+
+	bs := tree.ns.(*BlockNodeStore).bs
+	prf, err := tree.GetRangeProof(testKeys[100], testKeys[200])
+	assert.NoError(t, err)
+	err = VerifyRangeProof(ctx, treeCid, testKeys[100], testKeys[200], testKeys[100:201], testVals[100:201], prf, bs)
+	assert.NoError(t, err)
+
+	_, err = tree.ScanPrefix(ctx, []byte{1})
+	assert.Error(t, err)
+
+	// mutations are applied in the order of the tree
+	assert.NoError(t, tree.Mutate())
+	for i := 0; i < len(testKeys); i += 2 {
+		assert.NoError(t, tree.Delete(ctx, testKeys[i]))
+	}
+	_, err = tree.Rebuild(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, tree.TreeCount(), uint32(5000))
+	for i := 1; i < len(testKeys); i += 100 {
+		k, _, err := tree.GetByIndex(uint32(i / 2))
+		assert.NoError(t, err)
+		assert.Equal(t, k, testKeys[i])
+	}
+
+	unknown := uint64(0x3fffff)
+	cfg.CompareFunction = &unknown
+	_, err = NewFramework(ctx, ns, cfg, nil)
+	assert.Error(t, err)
+}
+
+func TestMerge3(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(10000)
+	newKeys, newVals := RandomTestData(100)
+	valA := basicnode.NewString("a")
+	valB := basicnode.NewString("b")
+
+	buildSides := func() (*ProllyTree, *ProllyTree, *ProllyTree) {
+		base, _ := BuildTestTreeFromData(t, testKeys, testVals)
+		ours, _ := BuildTestTreeFromData(t, testKeys, testVals)
+		theirs, _ := BuildTestTreeFromData(t, testKeys, testVals)
+
+		assert.NoError(t, ours.Mutate())
+		assert.NoError(t, theirs.Mutate())
+		// changes only made by one side
+		assert.NoError(t, ours.Put(ctx, testKeys[10], valA))
+		assert.NoError(t, ours.Delete(ctx, testKeys[20]))
+		assert.NoError(t, ours.Put(ctx, newKeys[0], newVals[0]))
+		assert.NoError(t, theirs.Put(ctx, testKeys[5000], valB))
+		assert.NoError(t, theirs.Delete(ctx, testKeys[6000]))
+		assert.NoError(t, theirs.Put(ctx, newKeys[1], newVals[1]))
+		// the same change on both sides
+		assert.NoError(t, ours.Put(ctx, testKeys[7000], valA))
+		assert.NoError(t, theirs.Put(ctx, testKeys[7000], valA))
+		assert.NoError(t, ours.Delete(ctx, testKeys[7001]))
+		assert.NoError(t, theirs.Delete(ctx, testKeys[7001]))
+		// conflicts
+		assert.NoError(t, ours.Put(ctx, testKeys[8000], valA))
+		assert.NoError(t, theirs.Put(ctx, testKeys[8000], valB))
+		assert.NoError(t, ours.Put(ctx, testKeys[9000], valA))
+		assert.NoError(t, theirs.Delete(ctx, testKeys[9000]))
+		_, err := ours.Rebuild(ctx)
+		assert.NoError(t, err)
+		_, err = theirs.Rebuild(ctx)
+		assert.NoError(t, err)
+		return base, ours, theirs
+	}
+
+	checkMerged := func(tree *ProllyTree) {
+		val, err := tree.Get(testKeys[10])
+		assert.NoError(t, err)
+		assert.Equal(t, val, valA)
+		_, err = tree.Get(testKeys[20])
+		assert.Equal(t, err, KeyNotFound)
+		val, err = tree.Get(newKeys[0])
+		assert.NoError(t, err)
+		assert.Equal(t, val, newVals[0])
+		val, err = tree.Get(testKeys[5000])
+		assert.NoError(t, err)
+		assert.Equal(t, val, valB)
+		_, err = tree.Get(testKeys[6000])
+		assert.Equal(t, err, KeyNotFound)
+		val, err = tree.Get(newKeys[1])
+		assert.NoError(t, err)
+		assert.Equal(t, val, newVals[1])
+		val, err = tree.Get(testKeys[7000])
+		assert.NoError(t, err)
+		assert.Equal(t, val, valA)
+		_, err = tree.Get(testKeys[7001])
+		assert.Equal(t, err, KeyNotFound)
+		val, err = tree.Get(testKeys[100])
+		assert.NoError(t, err)
+		assert.Equal(t, val, testVals[100])
+	}
+
+	// conflicts are returned and keep the values of ours
+	base, ours, theirs := buildSides()
+	conflicts, err := ours.Merge3(ctx, base, theirs, nil)
+	assert.NoError(t, err)
+	checkMerged(ours)
+	assert.Equal(t, len(conflicts), 2)
+	assert.Equal(t, conflicts[0].Key, testKeys[8000])
+	assert.Equal(t, conflicts[0].Base, testVals[8000])
+	assert.Equal(t, conflicts[0].Ours, valA)
+	assert.Equal(t, conflicts[0].Theirs, valB)
+	assert.Equal(t, conflicts[1].Key, testKeys[9000])
+	assert.Nil(t, conflicts[1].Theirs)
+	val, err := ours.Get(testKeys[8000])
+	assert.NoError(t, err)
+	assert.Equal(t, val, valA)
+	assert.Equal(t, int(ours.TreeCount()), 9999)
+
+	// conflicts resolved by taking theirs
+	base, ours, theirs = buildSides()
+	conflicts, err = ours.Merge3(ctx, base, theirs, func(ctx context.Context, conflict *Conflict) (ipld.Node, error) {
+		return conflict.Theirs, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, len(conflicts), 0)
+	checkMerged(ours)
+	val, err = ours.Get(testKeys[8000])
+	assert.NoError(t, err)
+	assert.Equal(t, val, valB)
+	_, err = ours.Get(testKeys[9000])
+	assert.Equal(t, err, KeyNotFound)
+	assert.Equal(t, int(ours.TreeCount()), 9998)
+
+	// nothing to merge
+	conflicts, err = ours.Merge3(ctx, theirs, theirs, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, len(conflicts), 0)
+}
+
+func TestDiff(t *testing.T) {
+	testKeys, testVals := RandomTestData(10000)
+	newKeys, newVals := RandomTestData(10)
+	base, _ := BuildTestTreeFromData(t, testKeys, testVals)
+	other, _ := BuildTestTreeFromData(t, testKeys, testVals)
+
+	ctx := context.Background()
+	newVal := basicnode.NewString("new")
+	assert.NoError(t, other.Mutate())
+	assert.NoError(t, other.Put(ctx, newKeys[0], newVals[0]))
+	assert.NoError(t, other.Put(ctx, testKeys[0], newVal))
+	assert.NoError(t, other.Delete(ctx, testKeys[5000]))
+	assert.NoError(t, other.Delete(ctx, testKeys[9999]))
+	_, err := other.Rebuild(ctx)
+	assert.NoError(t, err)
+
+	expected := map[string]*DiffEntry{
+		string(newKeys[0]):     {Key: newKeys[0], Op: Add, New: newVals[0]},
+		string(testKeys[0]):    {Key: testKeys[0], Op: Modify, Old: testVals[0], New: newVal},
+		string(testKeys[5000]): {Key: testKeys[5000], Op: Remove, Old: testVals[5000]},
+		string(testKeys[9999]): {Key: testKeys[9999], Op: Remove, Old: testVals[9999]},
+	}
+
+	diffs, err := base.Diff(ctx, other)
+	assert.NoError(t, err)
+	var preKey []byte
+	for {
+		entry, err := diffs.NextEntry()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		assert.True(t, preKey == nil || bytes.Compare(preKey, entry.Key) < 0)
+		preKey = entry.Key
+
+		exp, ok := expected[string(entry.Key)]
+		assert.True(t, ok)
+		assert.DeepEqual(t, entry, exp)
+		delete(expected, string(entry.Key))
+	}
+	assert.Equal(t, len(expected), 0)
+
+	// no changes between the same trees
+	diffs, err = base.Diff(ctx, base)
+	assert.NoError(t, err)
+	_, err = diffs.NextEntry()
+	assert.Equal(t, err, io.EOF)
+}
+
+func TestDiffError(t *testing.T) {
+	testKeys, testVals := RandomTestData(10000)
+	base, _ := BuildTestTreeFromData(t, testKeys, testVals)
+	other, _ := BuildTestTreeFromData(t, testKeys[:5000], testVals[:5000])
+
+	// the error of reading blocks is delivered after the changes before it
+	other.ns = &failingNodeStore{NodeStore: other.ns, limit: 2}
+	diffs, err := base.Diff(context.Background(), other)
+	assert.NoError(t, err)
+	for {
+		_, err = diffs.NextEntry()
+		if err != nil {
+			break
+		}
+	}
+	assert.NotEqual(t, err, io.EOF)
+	assert.Equal(t, diffs.Err(), err)
+	_, err = diffs.NextEntry()
+	assert.Equal(t, err, io.EOF)
+
+	// canceled
+	other.ns = other.ns.(*failingNodeStore).NodeStore
+	ctx, cancel := context.WithCancel(context.Background())
+	diffs, err = base.Diff(ctx, other)
+	assert.NoError(t, err)
+	_, err = diffs.NextEntry()
+	assert.NoError(t, err)
+	cancel()
+	for {
+		_, err = diffs.NextEntry()
+		if err != nil {
+			break
+		}
+	}
+	assert.Equal(t, err, context.Canceled)
+
+	// closed by the consumer
+	diffs, err = base.Diff(context.Background(), other)
+	assert.NoError(t, err)
+	_, err = diffs.NextEntry()
+	assert.NoError(t, err)
+	assert.NoError(t, diffs.Close())
+	_, err = diffs.NextEntry()
+	assert.Equal(t, err, io.EOF)
+	// the producer stops after closing
+	for range diffs.ch {
+	}
+	assert.Equal(t, diffs.err, DiffsClosed)
+}
+
+func TestStructuralDiff(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(20000)
+	newKeys, newVals := RandomTestData(500)
+	base, _ := BuildTestTreeFromData(t, testKeys, testVals)
+	other, _ := BuildTestTreeFromData(t, testKeys, testVals)
+
+	// a few changes, the subtrees before them are skipped
+	assert.NoError(t, other.Mutate())
+	assert.NoError(t, other.Put(ctx, testKeys[19990], basicnode.NewString("new")))
+	assert.NoError(t, other.Delete(ctx, testKeys[19995]))
+	_, err := other.Rebuild(ctx)
+	assert.NoError(t, err)
+
+	baseStore := &failingNodeStore{NodeStore: base.ns, limit: 1 << 30}
+	otherStore := &failingNodeStore{NodeStore: other.ns, limit: 1 << 30}
+	base.ns, other.ns = baseStore, otherStore
+	count := 0
+	err = forEachChange(ctx, base, other, func(key []byte, old ipld.Node, new ipld.Node) error {
+		count++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, count, 2)
+	assert.True(t, baseStore.reads+otherStore.reads < 40)
+
+	// many changes, the result is the same as comparing all pairs
+	expected := make(map[string][2]ipld.Node)
+	assert.NoError(t, other.Mutate())
+	for i := range newKeys {
+		assert.NoError(t, other.Put(ctx, newKeys[i], newVals[i]))
+		expected[string(newKeys[i])] = [2]ipld.Node{nil, newVals[i]}
+	}
+	for i := 0; i < len(testKeys); i += 97 {
+		assert.NoError(t, other.Delete(ctx, testKeys[i]))
+		expected[string(testKeys[i])] = [2]ipld.Node{testVals[i], nil}
+	}
+	for i := 50; i < len(testKeys); i += 89 {
+		val := basicnode.NewInt(int64(i))
+		assert.NoError(t, other.Put(ctx, testKeys[i], val))
+		expected[string(testKeys[i])] = [2]ipld.Node{testVals[i], val}
+	}
+	// the same value
+	assert.NoError(t, other.Put(ctx, testKeys[51], testVals[51]))
+	_, err = other.Rebuild(ctx)
+	assert.NoError(t, err)
+	expected[string(testKeys[19995])] = [2]ipld.Node{testVals[19995], nil}
+	expected[string(testKeys[19990])] = [2]ipld.Node{testVals[19990], basicnode.NewString("new")}
+
+	err = forEachChange(ctx, base, other, func(key []byte, old ipld.Node, new ipld.Node) error {
+		exp, ok := expected[string(key)]
+		assert.True(t, ok)
+		assert.True(t, valueEqual(exp[0], old))
+		assert.True(t, valueEqual(exp[1], new))
+		delete(expected, string(key))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, len(expected), 0)
+}
+
+func TestDiffStats(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(10000)
+	newKeys, newVals := RandomTestData(100)
+	base, _ := BuildTestTreeFromData(t, testKeys, testVals)
+
+	// the tail of base is removed, only the paths around the end are read
+	other, _ := BuildTestTreeFromData(t, testKeys[:9000], testVals[:9000])
+	baseStore := &failingNodeStore{NodeStore: base.ns, limit: 1 << 30}
+	otherStore := &failingNodeStore{NodeStore: other.ns, limit: 1 << 30}
+	base.ns, other.ns = baseStore, otherStore
+	stats, err := base.DiffStats(ctx, other)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, stats, &DiffStats{
+		Removed: 1000,
+		Ranges:  []KeyRange{{Start: testKeys[9000], End: testKeys[9999]}},
+	})
+	assert.True(t, baseStore.reads+otherStore.reads < 20)
+	base.ns, other.ns = baseStore.NodeStore, otherStore.NodeStore
+
+	stats, err = other.DiffStats(ctx, base)
+	assert.NoError(t, err)
+	assert.Equal(t, stats.Added, uint32(1000))
+	assert.Equal(t, len(stats.Ranges), 1)
+
+	// a bulk insert in the middle, subtrees of inserted pairs are counted without being read. Boundaries only depend on
+	// keys, so the nodes after the inserted pairs are the same.
+	keyOnly := true
+	cfg := DefaultChunkConfig()
+	cfg.KeyOnlyChunking = &keyOnly
+	cfg.MaxNodeSize = 1 << 16
+	cfg.Strategy.Suffix.ChunkingFactor = 5
+	full := buildTreeWithConfig(t, cfg, testKeys, testVals)
+	middle := buildTreeWithConfig(t, cfg, append(append([][]byte{}, testKeys[:4000]...), testKeys[6000:]...),
+		append(append([]ipld.Node{}, testVals[:4000]...), testVals[6000:]...))
+	fullStore := &failingNodeStore{NodeStore: full.ns, limit: 1 << 30}
+	middleStore := &failingNodeStore{NodeStore: middle.ns, limit: 1 << 30}
+	full.ns, middle.ns = fullStore, middleStore
+	stats, err = middle.DiffStats(ctx, full)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, stats, &DiffStats{
+		Added:  2000,
+		Ranges: []KeyRange{{Start: testKeys[4000], End: testKeys[5999]}},
+	})
+	assert.True(t, middleStore.reads+fullStore.reads < 20)
+	middleStore.reads, fullStore.reads = 0, 0
+	stats, err = full.DiffStats(ctx, middle)
+	assert.NoError(t, err)
+	assert.Equal(t, stats.Removed, uint32(2000))
+	assert.Equal(t, len(stats.Ranges), 1)
+	assert.True(t, middleStore.reads+fullStore.reads < 20)
+
+	// compare with the changes computed from all pairs
+	assert.NoError(t, other.Mutate())
+	for i := 0; i < len(newKeys); i++ {
+		assert.NoError(t, other.Put(ctx, newKeys[i], newVals[i]))
+	}
+	for i := 100; i < 300; i++ {
+		assert.NoError(t, other.Delete(ctx, testKeys[i]))
+	}
+	for i := 1000; i < 9000; i += 37 {
+		assert.NoError(t, other.Put(ctx, testKeys[i], basicnode.NewInt(int64(i))))
+	}
+	_, err = other.Rebuild(ctx)
+	assert.NoError(t, err)
+
+	pairs := func(tree *ProllyTree) map[string]ipld.Node {
+		res := make(map[string]ipld.Node)
+		iter, err := tree.Search(ctx, []byte{}, nil)
+		assert.NoError(t, err)
+		for !iter.Done() {
+			k, v, err := iter.NextPair()
+			assert.NoError(t, err)
+			res[string(k)] = v
+		}
+		return res
+	}
+	basePairs, otherPairs := pairs(base), pairs(other)
+	var allKeys []string
+	for k := range basePairs {
+		allKeys = append(allKeys, k)
+	}
+	for k := range otherPairs {
+		if _, ok := basePairs[k]; !ok {
+			allKeys = append(allKeys, k)
+		}
+	}
+	sort.Strings(allKeys)
+
+	expected := &DiffStats{}
+	extending := false
+	for _, k := range allKeys {
+		oldVal, newVal := basePairs[k], otherPairs[k]
+		switch {
+		case oldVal == nil:
+			expected.Added++
+		case newVal == nil:
+			expected.Removed++
+		case !valueEqual(oldVal, newVal):
+			expected.Modified++
+		default:
+			extending = false
+			continue
+		}
+		if extending {
+			expected.Ranges[len(expected.Ranges)-1].End = []byte(k)
+		} else {
+			expected.Ranges = append(expected.Ranges, KeyRange{Start: []byte(k), End: []byte(k)})
+			extending = true
+		}
+	}
+
+	stats, err = base.DiffStats(ctx, other)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, stats, expected)
+}
@@ -0,0 +1,210 @@
+package tree
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	"github.com/ipld/go-ipld-prime/schema"
+)
+
+var (
+	InvalidProof = errors.New("invalid proof")
+)
+
+// BlockGetter provides the raw blocks needed to verify proofs, blockstore.Blockstore satisfies it
+type BlockGetter interface {
+	Get(ctx context.Context, c cid.Cid) (blocks.Block, error)
+}
+
+// VerifyProof checks that the key/value pair is in the tree with rootCid. The proof is the one returned by
+// ProllyTree.GetProof, every block along the path is fetched from bg and checked against its cid, so the blocks
+// can come from an untrusted source. It returns nil if the proof is valid.
+func VerifyProof(ctx context.Context, rootCid cid.Cid, key []byte, value ipld.Node, proof Proof, bg BlockGetter) error {
+	_, err := VerifyProofRank(ctx, rootCid, key, value, proof, bg)
+	return err
+}
+
+// VerifyProofRank checks the proof like VerifyProof and returns the position of the key in the tree, that is the number
+// of keys smaller than it. The position is computed from the SubtreeCount of the entries before the path in each level,
+// so the n-th entry(counting from 1) of the tree has rank n-1.
+func VerifyProofRank(ctx context.Context, rootCid cid.Cid, key []byte, value ipld.Node, proof Proof, bg BlockGetter) (uint32, error) {
+	nodes, err := verifyProofPath(ctx, rootCid, proof, bg)
+	if err != nil {
+		return 0, err
+	}
+
+	leaf, idx := nodes[0], proof[0].Index
+	if !bytes.Equal(leaf.GetIdxKey(idx), key) {
+		return 0, fmt.Errorf("%w: key mismatch in leaf node", InvalidProof)
+	}
+	if !bytes.Equal(EncodeNode(leaf.GetIdxValue(idx)), EncodeNode(value)) {
+		return 0, fmt.Errorf("%w: value mismatch in leaf node", InvalidProof)
+	}
+
+	return proofRank(nodes, proof)
+}
+
+// verifyProofPath walks the proof from the ProllyRoot down to the leaf node, it checks the links between segments and
+// the index in every segment. It returns the verified ProllyNodes, nodes[i] is the node of proof[i] and nodes[0] is
+// the leaf node.
+func verifyProofPath(ctx context.Context, rootCid cid.Cid, proof Proof, bg BlockGetter) ([]*ProllyNode, error) {
+	if len(proof) < 2 {
+		return nil, fmt.Errorf("%w: too few segments", InvalidProof)
+	}
+	if !proof[len(proof)-1].Node.Equals(rootCid) {
+		return nil, fmt.Errorf("%w: last segment is not the tree root", InvalidProof)
+	}
+
+	root, err := loadVerifiedProllyRoot(ctx, bg, rootCid)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*ProllyNode, len(proof)-1)
+	expected := root.Root
+	var parentKey []byte
+	var parentCount uint32
+	for i := len(proof) - 2; i >= 0; i-- {
+		seg := proof[i]
+		if !seg.Node.Equals(expected) {
+			return nil, fmt.Errorf("%w: segment %d is not linked by its parent", InvalidProof, i)
+		}
+		nd, err := loadVerifiedProllyNode(ctx, bg, seg.Node)
+		if err != nil {
+			return nil, err
+		}
+		if seg.Index < 0 || seg.Index >= nd.ItemCount() {
+			return nil, fmt.Errorf("%w: index %d out of range in segment %d", InvalidProof, seg.Index, i)
+		}
+		// the key in branch node is the last key of the child node
+		if parentKey != nil && !bytes.Equal(nd.GetIdxKey(nd.ItemCount()-1), parentKey) {
+			return nil, fmt.Errorf("%w: last key of segment %d mismatches its parent", InvalidProof, i)
+		}
+		// the subtree count in branch node is the pairs count of the child node
+		if parentKey != nil && nd.totalPairCount() != parentCount {
+			return nil, fmt.Errorf("%w: pairs count of segment %d mismatches its parent", InvalidProof, i)
+		}
+		nodes[i] = nd
+
+		if i == 0 {
+			if !nd.IsLeaf {
+				return nil, fmt.Errorf("%w: first segment is not a leaf node", InvalidProof)
+			}
+			break
+		}
+
+		if nd.IsLeaf {
+			return nil, fmt.Errorf("%w: unexpected leaf node in segment %d", InvalidProof, i)
+		}
+		expected, err = proofNodeLink(nd, seg.Index)
+		if err != nil {
+			return nil, err
+		}
+		if len(nd.SubtreeCount) != nd.ItemCount() {
+			return nil, fmt.Errorf("%w: subtree counts mismatch keys in segment %d", InvalidProof, i)
+		}
+		parentKey = nd.GetIdxKey(seg.Index)
+		parentCount = nd.GetIdxTreeCount(seg.Index)
+	}
+
+	return nodes, nil
+}
+
+// proofRank returns the number of pairs before the one proved by the path, it sums the SubtreeCount of the entries
+// before the path in every level
+func proofRank(nodes []*ProllyNode, proof Proof) (uint32, error) {
+	var rank uint32
+	for i, nd := range nodes {
+		if len(nd.SubtreeCount) != nd.ItemCount() {
+			return 0, fmt.Errorf("%w: subtree counts mismatch keys in segment %d", InvalidProof, i)
+		}
+		for j := 0; j < proof[i].Index; j++ {
+			rank += nd.GetIdxTreeCount(j)
+		}
+	}
+	return rank, nil
+}
+
+func proofNodeLink(nd *ProllyNode, idx int) (cid.Cid, error) {
+	link, err := nd.GetIdxValue(idx).AsLink()
+	if err != nil {
+		return cid.Undef, fmt.Errorf("%w: expected link in branch node: %v", InvalidProof, err)
+	}
+	cl, ok := link.(cidlink.Link)
+	if !ok {
+		return cid.Undef, fmt.Errorf("%w: expected cidlink in branch node", InvalidProof)
+	}
+	return cl.Cid, nil
+}
+
+func loadVerifiedProllyRoot(ctx context.Context, bg BlockGetter, c cid.Cid) (*ProllyTree, error) {
+	nd, err := loadVerifiedBlock(ctx, bg, c, ProllyTreePrototype)
+	if err != nil {
+		return nil, err
+	}
+	root, err := UnwrapProllyTree(nd)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidProof, err)
+	}
+	return root, nil
+}
+
+func loadVerifiedProllyNode(ctx context.Context, bg BlockGetter, c cid.Cid) (*ProllyNode, error) {
+	nd, err := loadVerifiedBlock(ctx, bg, c, ProllyNodePrototype)
+	if err != nil {
+		return nil, err
+	}
+	pn, err := UnwrapProllyNode(nd)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidProof, err)
+	}
+	return pn, nil
+}
+
+// loadVerifiedCompareFunc returns the key order recorded in the config of the tree with rootCid
+func loadVerifiedCompareFunc(ctx context.Context, bg BlockGetter, rootCid cid.Cid) (CompareFunc, error) {
+	root, err := loadVerifiedProllyRoot(ctx, bg, rootCid)
+	if err != nil {
+		return nil, err
+	}
+	nd, err := loadVerifiedBlock(ctx, bg, root.Config, ChunkConfigPrototype)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := UnwrapChunkConfig(nd)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidProof, err)
+	}
+	return cfg.CompareFunc()
+}
+
+// loadVerifiedBlock gets the block from bg and makes sure its content matches the cid before decoding it
+func loadVerifiedBlock(ctx context.Context, bg BlockGetter, c cid.Cid, proto schema.TypedPrototype) (ipld.Node, error) {
+	blk, err := bg.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := c.Prefix().Sum(blk.RawData())
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, fmt.Errorf("%w: block data mismatches cid %s", InvalidProof, c)
+	}
+
+	decoder, err := multicodec.LookupDecoder(c.Prefix().Codec)
+	if err != nil {
+		return nil, err
+	}
+	nd, err := ipld.DecodeUsingPrototype(blk.RawData(), decoder, proto.Representation())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidProof, err)
+	}
+	return nd, nil
+}
@@ -0,0 +1,201 @@
+package tree
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	"io"
+)
+
+var _ BlockGetter = &ProofBundle{}
+
+// ProofBundle is a self-contained proof, it includes the proof segments and the encoded block of each segment
+type ProofBundle struct {
+	Proof Proof
+	// Blocks[i] is the raw block of Proof[i].Node
+	Blocks [][]byte
+	// Config is the raw block of the TreeConfig of the tree, so exclusion proofs of the boundary keys can be verified
+	// with the bundle too. It is nil in bundles encoded without it.
+	Config []byte
+}
+
+func (pt *ProllyTree) GetProofBundle(ctx context.Context, key []byte) (*ProofBundle, error) {
+	proof, err := pt.GetProof(key)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &ProofBundle{
+		Proof:  proof,
+		Blocks: make([][]byte, 0, len(proof)),
+	}
+	for _, seg := range proof {
+		raw, err := pt.ns.LinkSystem().LoadRaw(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: seg.Node})
+		if err != nil {
+			return nil, err
+		}
+		bundle.Blocks = append(bundle.Blocks, raw)
+	}
+	bundle.Config, err = pt.ns.LinkSystem().LoadRaw(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: pt.Config})
+	if err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+// configCid returns the cid of the TreeConfig in the ProllyRoot, which is the last segment of the proof
+func (pb *ProofBundle) configCid(ctx context.Context) (cid.Cid, error) {
+	if len(pb.Proof) == 0 {
+		return cid.Undef, fmt.Errorf("%w: empty proof in bundle", InvalidProof)
+	}
+	root, err := loadVerifiedProllyRoot(ctx, pb, pb.Proof[len(pb.Proof)-1].Node)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return root.Config, nil
+}
+
+// Get returns the block in the bundle, the data is not checked here but in verification
+func (pb *ProofBundle) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if len(pb.Blocks) != len(pb.Proof) {
+		return nil, fmt.Errorf("%w: blocks mismatch segments in bundle", InvalidProof)
+	}
+	for i, seg := range pb.Proof {
+		if seg.Node.Equals(c) {
+			return blocks.NewBlockWithCid(pb.Blocks[i], c)
+		}
+	}
+	// the config is not a segment, it is found by its hash
+	if pb.Config != nil {
+		sum, err := c.Prefix().Sum(pb.Config)
+		if err == nil && sum.Equals(c) {
+			return blocks.NewBlockWithCid(pb.Config, c)
+		}
+	}
+	return nil, fmt.Errorf("block %s not found in proof bundle", c)
+}
+
+// Verify checks the key/value pair is in the tree with rootCid using only the blocks in the bundle
+func (pb *ProofBundle) Verify(ctx context.Context, rootCid cid.Cid, key []byte, value ipld.Node) error {
+	return VerifyProof(ctx, rootCid, key, value, pb.Proof, pb)
+}
+
+// VerifyRank checks the pair like Verify and returns the position of the key in the tree
+func (pb *ProofBundle) VerifyRank(ctx context.Context, rootCid cid.Cid, key []byte, value ipld.Node) (uint32, error) {
+	return VerifyProofRank(ctx, rootCid, key, value, pb.Proof, pb)
+}
+
+// WriteCar writes the bundle as a CARv1, the root of the car is the encoded Proof and the other blocks are the
+// blocks of the segments and the config
+func (pb *ProofBundle) WriteCar(w io.Writer) error {
+	if len(pb.Blocks) != len(pb.Proof) {
+		return fmt.Errorf("%w: blocks mismatch segments in bundle", InvalidProof)
+	}
+	proofNode, err := pb.Proof.ToNode()
+	if err != nil {
+		return err
+	}
+	proofBytes, err := ipld.Encode(proofNode, dagcbor.Encode)
+	if err != nil {
+		return err
+	}
+	proofCid, err := DefaultLinkProto.Prefix.Sum(proofBytes)
+	if err != nil {
+		return err
+	}
+
+	err = car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{proofCid}, Version: 1}, w)
+	if err != nil {
+		return err
+	}
+	err = carutil.LdWrite(w, proofCid.Bytes(), proofBytes)
+	if err != nil {
+		return err
+	}
+	for i, seg := range pb.Proof {
+		err = carutil.LdWrite(w, seg.Node.Bytes(), pb.Blocks[i])
+		if err != nil {
+			return err
+		}
+	}
+	if pb.Config != nil {
+		configCid, err := pb.configCid(context.Background())
+		if err != nil {
+			return err
+		}
+		err = carutil.LdWrite(w, configCid.Bytes(), pb.Config)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadProofBundleCar reads the bundle written by ProofBundle.WriteCar
+func ReadProofBundleCar(r io.Reader) (*ProofBundle, error) {
+	cr, err := car.NewCarReader(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	if len(cr.Header.Roots) != 1 {
+		return nil, fmt.Errorf("expected one root in proof bundle car, got %d", len(cr.Header.Roots))
+	}
+	proofCid := cr.Header.Roots[0]
+
+	blks := make(map[cid.Cid][]byte)
+	for {
+		blk, err := cr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		blks[blk.Cid()] = blk.RawData()
+	}
+
+	proofBytes, ok := blks[proofCid]
+	if !ok {
+		return nil, fmt.Errorf("proof block %s not found in car", proofCid)
+	}
+	decoder, err := multicodec.LookupDecoder(proofCid.Prefix().Codec)
+	if err != nil {
+		return nil, err
+	}
+	proofNode, err := ipld.DecodeUsingPrototype(proofBytes, decoder, ProofPrototype.Representation())
+	if err != nil {
+		return nil, err
+	}
+	proof, err := UnwrapProof(proofNode)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &ProofBundle{
+		Proof:  *proof,
+		Blocks: make([][]byte, 0, len(*proof)),
+	}
+	for _, seg := range *proof {
+		raw, ok := blks[seg.Node]
+		if !ok {
+			return nil, fmt.Errorf("block %s not found in car", seg.Node)
+		}
+		bundle.Blocks = append(bundle.Blocks, raw)
+	}
+	configCid, err := bundle.configCid(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	// absent in cars written without the config
+	bundle.Config = blks[configCid]
+
+	return bundle, nil
+}
@@ -0,0 +1,133 @@
+package tree
+
+import (
+	"context"
+	"fmt"
+	"github.com/ipfs/go-cid"
+)
+
+// ExclusionProof proves a key is not in the tree with the proofs of the two adjacent pairs around the key
+type ExclusionProof struct {
+	// proof of the biggest key smaller than the absent key, nil if the key is smaller than all keys in the tree
+	Left Proof
+	// proof of the smallest key bigger than the absent key, nil if the key is bigger than all keys in the tree
+	Right Proof
+}
+
+// GetExclusionProof returns the proof that the key is not in the tree. Both Left and Right are nil if the tree is empty.
+func (pt *ProllyTree) GetExclusionProof(key []byte) (*ExclusionProof, error) {
+	if pt.mutating {
+		return nil, fmt.Errorf("Cannot get proof while tree is being mutated. Apply changes with Rebuild first.")
+	}
+	if pt.root.IsEmpty() {
+		return &ExclusionProof{}, nil
+	}
+
+	cur, err := CursorAtItem(&pt.root, key, pt.compareFunc, pt.ns)
+	if err != nil {
+		return nil, err
+	}
+	if !cur.IsValid() {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	cmp := pt.compareFunc(cur.GetKey(), key)
+	if cmp == 0 {
+		return nil, fmt.Errorf("key exists in the tree")
+	}
+
+	prf := &ExclusionProof{}
+	// the key is bigger than all keys in the tree
+	if cmp < 0 {
+		prf.Left = pt.proofFromCursor(cur)
+		return prf, nil
+	}
+
+	prf.Right = pt.proofFromCursor(cur.clone())
+	err = cur.Retreat()
+	if err != nil {
+		return nil, err
+	}
+	if cur.IsValid() {
+		prf.Left = pt.proofFromCursor(cur)
+	}
+
+	return prf, nil
+}
+
+// VerifyExclusionProof checks the key is not in the tree with rootCid. The pairs in Left and Right must be in the tree,
+// around the key and adjacent, the adjacency is checked by the positions computed from SubtreeCount along the paths.
+// Besides the blocks of the paths, bg must provide the TreeConfig block of the tree for the key order, ProofBundle
+// includes it.
+func VerifyExclusionProof(ctx context.Context, rootCid cid.Cid, key []byte, proof *ExclusionProof, bg BlockGetter) error {
+	if proof == nil {
+		return fmt.Errorf("%w: nil proof", InvalidProof)
+	}
+
+	// empty tree
+	if proof.Left == nil && proof.Right == nil {
+		root, err := loadVerifiedProllyRoot(ctx, bg, rootCid)
+		if err != nil {
+			return err
+		}
+		rootNode, err := loadVerifiedProllyNode(ctx, bg, root.Root)
+		if err != nil {
+			return err
+		}
+		if !rootNode.IsEmpty() {
+			return fmt.Errorf("%w: no neighbours in non-empty tree", InvalidProof)
+		}
+		return nil
+	}
+
+	cp, err := loadVerifiedCompareFunc(ctx, bg, rootCid)
+	if err != nil {
+		return err
+	}
+
+	var leftRank, rightRank, total uint32
+	if proof.Left != nil {
+		nodes, err := verifyProofPath(ctx, rootCid, proof.Left, bg)
+		if err != nil {
+			return err
+		}
+		if cp(nodes[0].GetIdxKey(proof.Left[0].Index), key) >= 0 {
+			return fmt.Errorf("%w: left key is not smaller than the key", InvalidProof)
+		}
+		leftRank, err = proofRank(nodes, proof.Left)
+		if err != nil {
+			return err
+		}
+		total = nodes[len(nodes)-1].totalPairCount()
+	}
+	if proof.Right != nil {
+		nodes, err := verifyProofPath(ctx, rootCid, proof.Right, bg)
+		if err != nil {
+			return err
+		}
+		if cp(nodes[0].GetIdxKey(proof.Right[0].Index), key) <= 0 {
+			return fmt.Errorf("%w: right key is not bigger than the key", InvalidProof)
+		}
+		rightRank, err = proofRank(nodes, proof.Right)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case proof.Left == nil:
+		if rightRank != 0 {
+			return fmt.Errorf("%w: right key is not the first key", InvalidProof)
+		}
+	case proof.Right == nil:
+		if leftRank+1 != total {
+			return fmt.Errorf("%w: left key is not the last key", InvalidProof)
+		}
+	default:
+		if leftRank+1 != rightRank {
+			return fmt.Errorf("%w: left and right keys are not adjacent", InvalidProof)
+		}
+	}
+
+	return nil
+}
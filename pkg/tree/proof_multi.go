@@ -0,0 +1,168 @@
+package tree
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+)
+
+// MultiProof proves many keys at once, the nodes shared by the paths of different keys are only recorded once
+type MultiProof struct {
+	// cid of the ProllyRoot
+	Tree cid.Cid
+	// distinct nodes on the paths, parent nodes are before their children
+	Nodes []cid.Cid
+	// Paths[i] is the index of the i-th key in every level, from the root node to the leaf node
+	Paths [][]int
+}
+
+// clone returns a deep copy of the proof
+func (mp *MultiProof) clone() *MultiProof {
+	res := &MultiProof{
+		Tree:  mp.Tree,
+		Nodes: append([]cid.Cid{}, mp.Nodes...),
+		Paths: make([][]int, len(mp.Paths)),
+	}
+	for i, path := range mp.Paths {
+		res.Paths[i] = append([]int{}, path...)
+	}
+	return res
+}
+
+// GetMultiProof returns the proof of all keys, it returns KeyNotFound if any key is not in the tree
+func (pt *ProllyTree) GetMultiProof(keys [][]byte) (*MultiProof, error) {
+	if pt.mutating {
+		return nil, fmt.Errorf("Cannot get proof while tree is being mutated. Apply changes with Rebuild first.")
+	}
+
+	prf := &MultiProof{
+		Tree:  *pt.treeCid,
+		Paths: make([][]int, 0, len(keys)),
+	}
+	seen := make(map[cid.Cid]struct{})
+	addNode := func(c cid.Cid) {
+		if _, ok := seen[c]; !ok {
+			seen[c] = struct{}{}
+			prf.Nodes = append(prf.Nodes, c)
+		}
+	}
+
+	for _, key := range keys {
+		cur, err := CursorAtItem(&pt.root, key, pt.compareFunc, pt.ns)
+		if err != nil {
+			return nil, err
+		}
+		if !cur.IsValid() || pt.compareFunc(cur.GetKey(), key) != 0 {
+			return nil, KeyNotFound
+		}
+
+		// collect cursors from the root to the leaf
+		var curs []*Cursor
+		for c := cur; c != nil; c = c.parent {
+			curs = append([]*Cursor{c}, curs...)
+		}
+
+		path := make([]int, 0, len(curs))
+		addNode(pt.Root)
+		for i, c := range curs {
+			path = append(path, c.GetIndex())
+			if i < len(curs)-1 {
+				addNode(c.GetLink())
+			}
+		}
+		prf.Paths = append(prf.Paths, path)
+	}
+
+	return prf, nil
+}
+
+// VerifyMultiProof checks every keys[i]/vals[i] pair is in the tree with rootCid, only the nodes in the proof are
+// loaded from bg and each of them is loaded once
+func VerifyMultiProof(ctx context.Context, rootCid cid.Cid, keys [][]byte, vals []ipld.Node, proof *MultiProof, bg BlockGetter) error {
+	if proof == nil {
+		return fmt.Errorf("%w: nil proof", InvalidProof)
+	}
+	if len(keys) != len(vals) {
+		return fmt.Errorf("keys and vals must have the same length")
+	}
+	if len(proof.Paths) != len(keys) {
+		return fmt.Errorf("%w: expected %d paths, got %d", InvalidProof, len(keys), len(proof.Paths))
+	}
+	if !proof.Tree.Equals(rootCid) {
+		return fmt.Errorf("%w: proof is not for the tree root", InvalidProof)
+	}
+
+	nodes := make(map[cid.Cid]*ProllyNode, len(proof.Nodes))
+	for _, c := range proof.Nodes {
+		nodes[c] = nil
+	}
+	loadNode := func(c cid.Cid) (*ProllyNode, error) {
+		nd, ok := nodes[c]
+		if !ok {
+			return nil, fmt.Errorf("%w: node %s is not in the proof", InvalidProof, c)
+		}
+		if nd != nil {
+			return nd, nil
+		}
+		nd, err := loadVerifiedProllyNode(ctx, bg, c)
+		if err != nil {
+			return nil, err
+		}
+		nodes[c] = nd
+		return nd, nil
+	}
+
+	root, err := loadVerifiedProllyRoot(ctx, bg, rootCid)
+	if err != nil {
+		return err
+	}
+
+	for i, path := range proof.Paths {
+		if len(path) == 0 {
+			return fmt.Errorf("%w: empty path for key %d", InvalidProof, i)
+		}
+		nd, err := loadNode(root.Root)
+		if err != nil {
+			return err
+		}
+		for level, idx := range path {
+			if idx < 0 || idx >= nd.ItemCount() {
+				return fmt.Errorf("%w: index %d out of range for key %d", InvalidProof, idx, i)
+			}
+			if level == len(path)-1 {
+				break
+			}
+			if nd.IsLeaf {
+				return fmt.Errorf("%w: unexpected leaf node in path of key %d", InvalidProof, i)
+			}
+			link, err := proofNodeLink(nd, idx)
+			if err != nil {
+				return err
+			}
+			parentKey := nd.GetIdxKey(idx)
+			nd, err = loadNode(link)
+			if err != nil {
+				return err
+			}
+			// the key in branch node is the last key of the child node
+			if nd.IsEmpty() || !bytes.Equal(nd.GetIdxKey(nd.ItemCount()-1), parentKey) {
+				return fmt.Errorf("%w: last key of child node mismatches its parent", InvalidProof)
+			}
+		}
+
+		idx := path[len(path)-1]
+		if !nd.IsLeaf {
+			return fmt.Errorf("%w: path of key %d does not end at leaf node", InvalidProof, i)
+		}
+		if !bytes.Equal(nd.GetIdxKey(idx), keys[i]) {
+			return fmt.Errorf("%w: key mismatch for key %d", InvalidProof, i)
+		}
+		if !bytes.Equal(EncodeNode(nd.GetIdxValue(idx)), EncodeNode(vals[i])) {
+			return fmt.Errorf("%w: value mismatch for key %d", InvalidProof, i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package tree
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+)
+
+// RangeProof proves the pairs returned for [start, end] are all pairs in the interval. It lists every node whose key
+// interval overlaps [start, end] once, so the path from the root node to where the boundaries split is shared, and the
+// verifier only needs these nodes to collect the pairs in range.
+type RangeProof struct {
+	// cid of the ProllyRoot
+	Tree cid.Cid
+	// nodes overlapping the range in depth-first order, the root node is the first one and parent nodes are before
+	// their children
+	Nodes []cid.Cid
+}
+
+// rangeChildNeeded returns whether the idx-th child of the branch node may hold keys in [start, end]. The child holds
+// the keys in (key[idx-1], key[idx]] as the key in branch node is the last key of the child node.
+func rangeChildNeeded(nd *ProllyNode, idx int, start []byte, end []byte, cp CompareFunc) bool {
+	if start != nil && cp(nd.GetIdxKey(idx), start) < 0 {
+		return false
+	}
+	if end != nil && idx > 0 && cp(nd.GetIdxKey(idx-1), end) >= 0 {
+		return false
+	}
+	return true
+}
+
+// GetRangeProof returns the range proof for [start, end], nil start or end means the interval is unbounded on that side
+func (pt *ProllyTree) GetRangeProof(start []byte, end []byte) (*RangeProof, error) {
+	if pt.mutating || pt.treeCid == nil {
+		return nil, fmt.Errorf("Cannot get proof while tree is being mutated. Apply changes with Rebuild first.")
+	}
+	prf := &RangeProof{
+		Tree:  *pt.treeCid,
+		Nodes: []cid.Cid{pt.Root},
+	}
+
+	var collect func(nd *ProllyNode) error
+	collect = func(nd *ProllyNode) error {
+		if nd.IsLeaf {
+			return nil
+		}
+		for i := 0; i < nd.ItemCount(); i++ {
+			if !rangeChildNeeded(nd, i, start, end, pt.compareFunc) {
+				continue
+			}
+			link := getCidFromIpldNode(nd.Values[i])
+			child, err := pt.ns.ReadNode(context.Background(), link)
+			if err != nil {
+				return err
+			}
+			prf.Nodes = append(prf.Nodes, link)
+			err = collect(child)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := collect(&pt.root)
+	if err != nil {
+		return nil, err
+	}
+
+	return prf, nil
+}
+
+// VerifyRangeProof checks keys and vals are exactly the pairs in [start, end] of the tree with rootCid, in ascending
+// order. Only the nodes listed in the proof are loaded from bg, bg must also provide the TreeConfig block of the tree
+// for the key order.
+func VerifyRangeProof(ctx context.Context, rootCid cid.Cid, start []byte, end []byte, keys [][]byte, vals []ipld.Node, proof *RangeProof, bg BlockGetter) error {
+	if proof == nil {
+		return fmt.Errorf("%w: nil proof", InvalidProof)
+	}
+	if len(keys) != len(vals) {
+		return fmt.Errorf("keys and vals must have the same length")
+	}
+	if !proof.Tree.Equals(rootCid) {
+		return fmt.Errorf("%w: proof is not for the tree root", InvalidProof)
+	}
+	if len(proof.Nodes) == 0 {
+		return fmt.Errorf("%w: no nodes", InvalidProof)
+	}
+
+	cp, err := loadVerifiedCompareFunc(ctx, bg, rootCid)
+	if err != nil {
+		return err
+	}
+	prollyRoot, err := loadVerifiedProllyRoot(ctx, bg, rootCid)
+	if err != nil {
+		return err
+	}
+	if !proof.Nodes[0].Equals(prollyRoot.Root) {
+		return fmt.Errorf("%w: first node is not the root node", InvalidProof)
+	}
+
+	w := &rangeWalker{
+		ctx:   ctx,
+		bg:    bg,
+		nodes: proof.Nodes,
+		start: start,
+		end:   end,
+		cp:    cp,
+	}
+	root, err := w.next()
+	if err != nil {
+		return err
+	}
+	err = w.walk(root)
+	if err != nil {
+		return err
+	}
+	if len(w.nodes) != 0 {
+		return fmt.Errorf("%w: %d unexpected nodes", InvalidProof, len(w.nodes))
+	}
+
+	if len(w.keys) != len(keys) {
+		return fmt.Errorf("%w: expected %d pairs in range, got %d", InvalidProof, len(w.keys), len(keys))
+	}
+	for i := range keys {
+		if !bytes.Equal(w.keys[i], keys[i]) {
+			return fmt.Errorf("%w: key mismatch at %d", InvalidProof, i)
+		}
+		if !bytes.Equal(EncodeNode(w.vals[i]), EncodeNode(vals[i])) {
+			return fmt.Errorf("%w: value mismatch at %d", InvalidProof, i)
+		}
+	}
+
+	return nil
+}
+
+// rangeWalker visits the nodes of a range proof in the order they are listed and collects the pairs in range
+type rangeWalker struct {
+	ctx   context.Context
+	bg    BlockGetter
+	nodes []cid.Cid
+	start []byte
+	end   []byte
+	cp    CompareFunc
+
+	keys [][]byte
+	vals []ipld.Node
+}
+
+// next loads the next node listed in the proof
+func (w *rangeWalker) next() (*ProllyNode, error) {
+	if len(w.nodes) == 0 {
+		return nil, fmt.Errorf("%w: missing nodes", InvalidProof)
+	}
+	nd, err := loadVerifiedProllyNode(w.ctx, w.bg, w.nodes[0])
+	if err != nil {
+		return nil, err
+	}
+	w.nodes = w.nodes[1:]
+	return nd, nil
+}
+
+// walk collects the pairs in range under nd, the children overlapping the range must be the next nodes in the proof
+func (w *rangeWalker) walk(nd *ProllyNode) error {
+	if nd.IsLeaf {
+		for i := 0; i < nd.ItemCount(); i++ {
+			key := nd.GetIdxKey(i)
+			if w.start != nil && w.cp(key, w.start) < 0 || w.end != nil && w.cp(key, w.end) > 0 {
+				continue
+			}
+			w.keys = append(w.keys, key)
+			w.vals = append(w.vals, nd.GetIdxValue(i))
+		}
+		return nil
+	}
+
+	for i := 0; i < nd.ItemCount(); i++ {
+		if !rangeChildNeeded(nd, i, w.start, w.end, w.cp) {
+			continue
+		}
+		link, err := proofNodeLink(nd, i)
+		if err != nil {
+			return err
+		}
+		if len(w.nodes) == 0 || !w.nodes[0].Equals(link) {
+			return fmt.Errorf("%w: node overlapping the range is not in the proof", InvalidProof)
+		}
+		child, err := w.next()
+		if err != nil {
+			return err
+		}
+		// the key in branch node is the last key of the child node
+		if child.IsEmpty() || !bytes.Equal(child.GetIdxKey(child.ItemCount()-1), nd.GetIdxKey(i)) {
+			return fmt.Errorf("%w: last key of child node mismatches its parent", InvalidProof)
+		}
+		err = w.walk(child)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
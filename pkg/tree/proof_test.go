@@ -0,0 +1,354 @@
+package tree
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/zeebo/assert"
+	"testing"
+	"time"
+)
+
+func TestVerifyProof(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(10000)
+	tree, treeCid := BuildTestTreeFromData(t, testKeys, testVals)
+	bs := tree.ns.(*BlockNodeStore).bs
+
+	for _, idx := range []int{0, 1, 4999, 9998, 9999} {
+		proof, err := tree.GetProof(testKeys[idx])
+		assert.NoError(t, err)
+		err = VerifyProof(ctx, treeCid, testKeys[idx], testVals[idx], proof, bs)
+		assert.NoError(t, err)
+	}
+
+	proof, err := tree.GetProof(testKeys[100])
+	assert.NoError(t, err)
+
+	// wrong value
+	err = VerifyProof(ctx, treeCid, testKeys[100], basicnode.NewString("wrong"), proof, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+
+	// wrong key
+	err = VerifyProof(ctx, treeCid, testKeys[101], testVals[100], proof, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+
+	// tampered index
+	tampered := make(Proof, len(proof))
+	copy(tampered, proof)
+	tampered[0].Index++
+	err = VerifyProof(ctx, treeCid, testKeys[100], testVals[100], tampered, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+
+	// proof of another tree
+	otherTree, otherCid := BuildTestTreeFromData(t, testKeys[:5000], testVals[:5000])
+	err = VerifyProof(ctx, otherCid, testKeys[100], testVals[100], proof, otherTree.ns.(*BlockNodeStore).bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+}
+
+func TestProofBundle(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(10000)
+	tree, treeCid := BuildTestTreeFromData(t, testKeys, testVals)
+
+	bundle, err := tree.GetProofBundle(ctx, testKeys[2000])
+	assert.NoError(t, err)
+	assert.Equal(t, len(bundle.Blocks), len(bundle.Proof))
+	assert.NoError(t, bundle.Verify(ctx, treeCid, testKeys[2000], testVals[2000]))
+
+	nd, err := bundle.ToNode()
+	assert.NoError(t, err)
+	encoded, err := ipld.Encode(nd, dagcbor.Encode)
+	assert.NoError(t, err)
+	nd, err = ipld.DecodeUsingPrototype(encoded, dagcbor.Decode, ProofBundlePrototype.Representation())
+	assert.NoError(t, err)
+	reBundle, err := UnwrapProofBundle(nd)
+	assert.NoError(t, err)
+	assert.Equal(t, reBundle.Proof, bundle.Proof)
+	assert.Equal(t, reBundle.Config, bundle.Config)
+	assert.NoError(t, reBundle.Verify(ctx, treeCid, testKeys[2000], testVals[2000]))
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, bundle.WriteCar(buf))
+	carBundle, err := ReadProofBundleCar(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, carBundle.Proof, bundle.Proof)
+	assert.NoError(t, carBundle.Verify(ctx, treeCid, testKeys[2000], testVals[2000]))
+
+	// tampered block
+	carBundle.Blocks[0] = carBundle.Blocks[1]
+	err = carBundle.Verify(ctx, treeCid, testKeys[2000], testVals[2000])
+	assert.True(t, errors.Is(err, InvalidProof))
+
+	// the bundle of the last key proves bigger keys are absent with the config for the key order
+	bundle, err = tree.GetProofBundle(ctx, testKeys[9999])
+	assert.NoError(t, err)
+	buf.Reset()
+	assert.NoError(t, bundle.WriteCar(buf))
+	carBundle, err = ReadProofBundleCar(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, carBundle.Config, bundle.Config)
+	absent := append(append([]byte{}, testKeys[9999]...), 0)
+	assert.NoError(t, VerifyExclusionProof(ctx, treeCid, absent, &ExclusionProof{Left: carBundle.Proof}, carBundle))
+
+	// bundles without the config are still decoded
+	bundle.Config = nil
+	nd, err = bundle.ToNode()
+	assert.NoError(t, err)
+	encoded, err = ipld.Encode(nd, dagcbor.Encode)
+	assert.NoError(t, err)
+	nd, err = ipld.DecodeUsingPrototype(encoded, dagcbor.Decode, ProofBundlePrototype.Representation())
+	assert.NoError(t, err)
+	reBundle, err = UnwrapProofBundle(nd)
+	assert.NoError(t, err)
+	assert.Nil(t, reBundle.Config)
+	assert.NoError(t, reBundle.Verify(ctx, treeCid, testKeys[9999], testVals[9999]))
+}
+
+func TestExclusionProof(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(10000)
+	tree, treeCid := BuildTestTreeFromData(t, testKeys, testVals)
+	bs := tree.ns.(*BlockNodeStore).bs
+
+	successor := func(key []byte) []byte {
+		res := make([]byte, len(key), len(key)+1)
+		copy(res, key)
+		return append(res, 0)
+	}
+
+	for idx := 0; idx < 9999; idx += 37 {
+		absentKey := successor(testKeys[idx])
+		prf, err := tree.GetExclusionProof(absentKey)
+		assert.NoError(t, err)
+		assert.NotNil(t, prf.Left)
+		assert.NotNil(t, prf.Right)
+		assert.NoError(t, VerifyExclusionProof(ctx, treeCid, absentKey, prf, bs))
+	}
+
+	// smaller than all keys
+	prf, err := tree.GetExclusionProof([]byte{})
+	assert.NoError(t, err)
+	assert.Nil(t, prf.Left)
+	assert.NoError(t, VerifyExclusionProof(ctx, treeCid, []byte{}, prf, bs))
+
+	// bigger than all keys
+	absentKey := successor(testKeys[9999])
+	prf, err = tree.GetExclusionProof(absentKey)
+	assert.NoError(t, err)
+	assert.Nil(t, prf.Right)
+	assert.NoError(t, VerifyExclusionProof(ctx, treeCid, absentKey, prf, bs))
+
+	// existing key
+	_, err = tree.GetExclusionProof(testKeys[100])
+	assert.Error(t, err)
+
+	// non-adjacent neighbours
+	left, err := tree.GetProof(testKeys[99])
+	assert.NoError(t, err)
+	right, err := tree.GetProof(testKeys[101])
+	assert.NoError(t, err)
+	err = VerifyExclusionProof(ctx, treeCid, successor(testKeys[100]), &ExclusionProof{Left: left, Right: right}, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+
+	// missing right edge
+	err = VerifyExclusionProof(ctx, treeCid, successor(testKeys[99]), &ExclusionProof{Left: left}, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+
+	// empty tree
+	ns := TestMemNodeStore()
+	framework, err := NewFramework(ctx, ns, DefaultChunkConfig(), nil)
+	assert.NoError(t, err)
+	emptyTree, emptyCid, err := framework.BuildTree(ctx)
+	assert.NoError(t, err)
+	prf, err = emptyTree.GetExclusionProof(testKeys[0])
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyExclusionProof(ctx, emptyCid, testKeys[0], prf, ns.(*BlockNodeStore).bs))
+	err = VerifyExclusionProof(ctx, treeCid, testKeys[0], prf, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+}
+
+func TestRangeProof(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(10000)
+	tree, treeCid := BuildTestTreeFromData(t, testKeys, testVals)
+	bs := tree.ns.(*BlockNodeStore).bs
+
+	ranges := []struct {
+		start []byte
+		end   []byte
+		from  int
+		to    int
+	}{
+		{testKeys[100], testKeys[3000], 100, 3001},
+		{append(testKeys[100], 0), testKeys[101], 101, 102},
+		{testKeys[0], testKeys[20], 0, 21},
+		{[]byte{}, testKeys[20], 0, 21},
+		{testKeys[9000], nil, 9000, 10000},
+		{nil, nil, 0, 10000},
+		{append(testKeys[100], 0), append(testKeys[100], 1), 101, 101},
+	}
+	for _, r := range ranges {
+		prf, err := tree.GetRangeProof(r.start, r.end)
+		assert.NoError(t, err)
+		err = VerifyRangeProof(ctx, treeCid, r.start, r.end, testKeys[r.from:r.to], testVals[r.from:r.to], prf, bs)
+		assert.NoError(t, err)
+	}
+
+	prf, err := tree.GetRangeProof(testKeys[100], testKeys[3000])
+	assert.NoError(t, err)
+
+	// nodes are listed once and only the listed nodes are needed
+	seen := make(map[cid.Cid]struct{})
+	for _, c := range prf.Nodes {
+		_, ok := seen[c]
+		assert.False(t, ok)
+		seen[c] = struct{}{}
+	}
+	listed := &listedBlockGetter{bg: bs, cids: map[cid.Cid]struct{}{treeCid: {}, tree.Config: {}}}
+	for _, c := range prf.Nodes {
+		listed.cids[c] = struct{}{}
+	}
+	assert.NoError(t, VerifyRangeProof(ctx, treeCid, testKeys[100], testKeys[3000], testKeys[100:3001], testVals[100:3001], prf, listed))
+
+	// missing and extra nodes
+	short := &RangeProof{Tree: prf.Tree, Nodes: prf.Nodes[:len(prf.Nodes)-1]}
+	err = VerifyRangeProof(ctx, treeCid, testKeys[100], testKeys[3000], testKeys[100:3001], testVals[100:3001], short, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+	long := &RangeProof{Tree: prf.Tree, Nodes: append(append([]cid.Cid{}, prf.Nodes...), prf.Nodes[1])}
+	err = VerifyRangeProof(ctx, treeCid, testKeys[100], testKeys[3000], testKeys[100:3001], testVals[100:3001], long, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+
+	// omitted pair
+	keys := append(append([][]byte{}, testKeys[100:2000]...), testKeys[2001:3001]...)
+	vals := append(append([]ipld.Node{}, testVals[100:2000]...), testVals[2001:3001]...)
+	err = VerifyRangeProof(ctx, treeCid, testKeys[100], testKeys[3000], keys, vals, prf, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+
+	// truncated range
+	err = VerifyRangeProof(ctx, treeCid, testKeys[100], testKeys[3000], testKeys[100:2999], testVals[100:2999], prf, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+
+	// proof of a range missing the nodes of the wider range
+	err = VerifyRangeProof(ctx, treeCid, testKeys[100], testKeys[6000], testKeys[100:6001], testVals[100:6001], prf, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+
+	// empty tree
+	ns := TestMemNodeStore()
+	framework, err := NewFramework(ctx, ns, DefaultChunkConfig(), nil)
+	assert.NoError(t, err)
+	emptyTree, emptyCid, err := framework.BuildTree(ctx)
+	assert.NoError(t, err)
+	prf, err = emptyTree.GetRangeProof(nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyRangeProof(ctx, emptyCid, nil, nil, nil, nil, prf, ns.(*BlockNodeStore).bs))
+	err = VerifyRangeProof(ctx, emptyCid, nil, nil, testKeys[:1], testVals[:1], prf, ns.(*BlockNodeStore).bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+}
+
+// listedBlockGetter only returns the blocks of the listed cids
+type listedBlockGetter struct {
+	bg   BlockGetter
+	cids map[cid.Cid]struct{}
+}
+
+func (g *listedBlockGetter) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if _, ok := g.cids[c]; !ok {
+		return nil, fmt.Errorf("block %s is not listed", c)
+	}
+	return g.bg.Get(ctx, c)
+}
+
+func TestMultiProof(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(10000)
+	tree, treeCid := BuildTestTreeFromData(t, testKeys, testVals)
+	bs := tree.ns.(*BlockNodeStore).bs
+
+	var keys [][]byte
+	var vals []ipld.Node
+	for i := 0; i < len(testKeys); i += 10 {
+		keys = append(keys, testKeys[i])
+		vals = append(vals, testVals[i])
+	}
+
+	prf, err := tree.GetMultiProof(keys)
+	assert.NoError(t, err)
+	assert.Equal(t, len(prf.Paths), len(keys))
+	assert.True(t, len(prf.Nodes) < len(keys))
+	assert.NoError(t, VerifyMultiProof(ctx, treeCid, keys, vals, prf, bs))
+
+	mps, ok := tree.ns.(MultiProofStore)
+	assert.True(t, ok)
+	c, err := mps.WriteMultiProof(ctx, prf, nil)
+	assert.NoError(t, err)
+	rePrf, err := mps.ReadMultiProof(ctx, c)
+	assert.NoError(t, err)
+	assert.Equal(t, rePrf, prf)
+
+	// the cached proof is not shared with callers, the cache is filled asynchronously after writing
+	deadline := time.Now().Add(time.Second)
+	for !tree.ns.(*BlockNodeStore).cache.Contains(c) {
+		if time.Now().After(deadline) {
+			t.Fatal("the written proof is not cached after 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	rePrf, err = mps.ReadMultiProof(ctx, c)
+	assert.NoError(t, err)
+	rePrf.Nodes[0] = cid.Undef
+	rePrf.Paths[0][0]++
+	rePrf, err = mps.ReadMultiProof(ctx, c)
+	assert.NoError(t, err)
+	assert.Equal(t, rePrf, prf)
+
+	lsysNs := NewLinkSystemNodeStore(tree.ns.LinkSystem())
+	rePrf, err = lsysNs.ReadMultiProof(ctx, c)
+	assert.NoError(t, err)
+	assert.Equal(t, rePrf, prf)
+	assert.NoError(t, VerifyMultiProof(ctx, treeCid, keys, vals, rePrf, bs))
+
+	// wrong value
+	wrongVals := append([]ipld.Node{}, vals...)
+	wrongVals[5] = basicnode.NewString("wrong")
+	err = VerifyMultiProof(ctx, treeCid, keys, wrongVals, prf, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+
+	// node missing from the proof
+	prf.Nodes = prf.Nodes[:len(prf.Nodes)-1]
+	err = VerifyMultiProof(ctx, treeCid, keys, vals, prf, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+
+	_, err = tree.GetMultiProof([][]byte{testKeys[0], []byte("absent key")})
+	assert.Equal(t, err, KeyNotFound)
+}
+
+func TestProofRank(t *testing.T) {
+	ctx := context.Background()
+	testKeys, testVals := RandomTestData(10000)
+	tree, treeCid := BuildTestTreeFromData(t, testKeys, testVals)
+	bs := tree.ns.(*BlockNodeStore).bs
+
+	for idx := 0; idx < len(testKeys); idx += 97 {
+		proof, err := tree.GetProof(testKeys[idx])
+		assert.NoError(t, err)
+		rank, err := VerifyProofRank(ctx, treeCid, testKeys[idx], testVals[idx], proof, bs)
+		assert.NoError(t, err)
+		assert.Equal(t, rank, uint32(idx))
+	}
+
+	bundle, err := tree.GetProofBundle(ctx, testKeys[9999])
+	assert.NoError(t, err)
+	rank, err := bundle.VerifyRank(ctx, treeCid, testKeys[9999], testVals[9999])
+	assert.NoError(t, err)
+	assert.Equal(t, rank, uint32(9999))
+
+	_, err = VerifyProofRank(ctx, treeCid, testKeys[9998], testVals[9998], bundle.Proof, bs)
+	assert.True(t, errors.Is(err, InvalidProof))
+}
@@ -22,6 +22,10 @@ var (
 
 	ProofSegmentPrototype schema.TypedPrototype
 
+	ProofBundlePrototype schema.TypedPrototype
+
+	MultiProofPrototype schema.TypedPrototype
+
 	//go:embed schema.ipldsch
 	schemaBytes []byte
 )
@@ -36,4 +40,6 @@ func init() {
 	ChunkConfigPrototype = bindnode.Prototype(&TreeConfig{}, typeSystem.TypeByName("TreeConfig"))
 	ProofSegmentPrototype = bindnode.Prototype(&ProofSegment{}, typeSystem.TypeByName("ProofSegment"))
 	ProofPrototype = bindnode.Prototype(&Proof{}, typeSystem.TypeByName("Proof"))
+	ProofBundlePrototype = bindnode.Prototype(&ProofBundle{}, typeSystem.TypeByName("ProofBundle"))
+	MultiProofPrototype = bindnode.Prototype(&MultiProof{}, typeSystem.TypeByName("MultiProof"))
 }
@@ -27,6 +27,13 @@ type NodeStore interface {
 	Close()
 }
 
+// MultiProofStore is implemented by the NodeStore which can also store multi-key proofs. It is not part of NodeStore
+// so other implementations of NodeStore still satisfy it, check it with a type assertion.
+type MultiProofStore interface {
+	WriteMultiProof(ctx context.Context, prf *MultiProof, prefix *cid.Prefix) (cid.Cid, error)
+	ReadMultiProof(ctx context.Context, c cid.Cid) (*MultiProof, error)
+}
+
 var DefaultLinkProto = cidlink.LinkPrototype{
 	Prefix: cid.Prefix{
 		Version:  1,